@@ -23,6 +23,7 @@ import (
 
 	"github.com/chenquan/diskusage/internal"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const BuildVersion = "1.2.8"
@@ -41,7 +42,22 @@ var rootCmd = &cobra.Command{
 4.Displays a 2-level tree structure: diskusage -d 2
 5.Specify the directory /usr: diskusage --dir /usr
 6.Export disk usage to file: diskusage > diskusage.txt
-7.Enable interactive: diskusage -i`,
+7.Enable interactive: diskusage -i
+8.Export the tree as JSON: diskusage -d 3 --format json > diskusage.json
+9.Stream one JSON record per path: diskusage --format ndjson | jq -c 'select(.size > 1e9)'
+10.Export a spreadsheet with full paths: diskusage -r --format csv > diskusage.csv
+11.Export and import ncdu dumps:
+  a.diskusage --dir /var --format ncdu > var.ncdu.json && ncdu -f var.ncdu.json
+  b.ncdu -o var.ncdu.json /var && diskusage --import var.ncdu.json -i
+12.Write an offline HTML report: diskusage -d 3 -c ignore --format html > report.html
+13.Render a flame graph: diskusage --format folded | flamegraph.pl > usage.svg
+14.Explore with pprof: diskusage --format pprof > usage.pb.gz && go tool pprof -http=: usage.pb.gz
+15.Find sparse files: diskusage --both-sizes -r
+16.Skip dependencies and ignored files: diskusage --exclude node_modules --exclude .git --ignore-files
+17.Count old large logs: diskusage -r --where 'size > 100M && ext == ".log" && mtime < 30d'
+18.Find large files not touched in 90 days: diskusage -r --min-size 100M --older-than 90d --time-field atime
+19.Find who filled /home: diskusage --dir /home --by owner
+20.See which file types dominate: diskusage --by type -u G`,
 	Long: `A tool for showing disk usage.
 
 GitHub: https://github.com/chenquan/diskusage
@@ -79,16 +95,45 @@ func Execute() {
 }
 
 func init() {
-	rootCmd.Flags().StringP("unit", "u", "M", "displayed units. optional: B(Bytes), K(KB), M(MB), G(GB), T(TB)")
-	rootCmd.Flags().String("dir", "./", "directory path")
-	rootCmd.Flags().Int64P("depth", "d", 1, "shows the depth of the tree directory structure")
-	rootCmd.Flags().StringSliceP("type", "t", []string{}, "only count certain types of files  (default all)")
-	rootCmd.Flags().StringP("filter", "f", "", "regular expressions are used to filter files")
-	rootCmd.Flags().BoolP("all", "a", false, "display all directories, otherwise only display folders whose usage size is not 0")
-	rootCmd.Flags().StringP("color", "c", "auto", "set color output mode. optional: auto, always, ignore")
-	rootCmd.Flags().IntP("worker", "w", 32, "number of workers searching the directory")
+	addScanFlags(rootCmd.Flags())
+	addDisplayFlags(rootCmd.Flags())
 	rootCmd.Flags().Int64P("limit", "l", math.MaxInt64, "limit the number of files and directories displayed")
-	rootCmd.Flags().BoolP("recursion", "r", false, "automatically calculate directory depth, for recursively traversing all sub directories")
 	rootCmd.Flags().BoolP("directory", "D", false, "only display directory")
-	rootCmd.Flags().BoolP("interactive", "i", false, "enable interactive")
+	rootCmd.Flags().BoolP("interactive", "i", false, "browse the tree interactively, expanding and opening directories with the arrow keys")
+	rootCmd.Flags().Bool("both-sizes", false, "display the allocated size, the apparent size and the allocated size in percent of the apparent size")
+	rootCmd.Flags().String("import", "", "read the tree from an ncdu JSON dump instead of scanning, - for stdin")
+	rootCmd.Flags().String("by", "", "report the usage per key instead of the tree. optional: owner, type (by extension), mime (by sniffing the content)")
+	rootCmd.Flags().String("format", "tree", "output format. optional: tree, json, ndjson, csv, tsv, ncdu, html, folded, pprof")
+}
+
+// addScanFlags adds the flags controlling which files are scanned and counted.
+func addScanFlags(flags *pflag.FlagSet) {
+	flags.String("dir", "./", "directory path")
+	flags.StringSliceP("type", "t", []string{}, "only count certain types of files  (default all)")
+	flags.StringP("filter", "f", "", "regular expressions are used to filter files")
+	flags.String("where", "", `only count the files matching an expression, e.g. 'size > 100M && path =~ "^logs/" && mtime < 30d'`)
+	flags.String("min-size", "", "only count the files of at least this size, e.g. 100M, and hide the smaller directories")
+	flags.String("max-size", "", "only count the files of at most this size, e.g. 1G")
+	flags.String("newer-than", "", "only count the files newer than an age like 7d or a date like 2006-01-02")
+	flags.String("older-than", "", "only count the files older than an age like 90d or a date like 2006-01-02")
+	flags.String("time-field", "mtime", "time compared by --newer-than and --older-than. optional: mtime, atime, ctime, btime")
+	flags.StringArray("exclude", []string{}, "skip the files and directories matching a gitignore style pattern, can be repeated")
+	flags.String("exclude-from", "", "read exclude patterns from a file, one per line")
+	flags.Bool("ignore-files", false, "honor the .gitignore and .duignore files found while scanning")
+	flags.IntP("worker", "w", 32, "number of workers searching the directory")
+	flags.BoolP("one-file-system", "x", false, "stay on the file system of the directory, skipping the mount points of other file systems")
+	flags.StringSlice("skip-fs", []string{"pseudo"}, "file system classes not descended into, linux only. optional: proc, sysfs, cgroup, devtmpfs, debugfs, tracefs, nfs, cifs, fuse, pseudo (all of proc to tracefs), network (nfs, cifs, fuse)")
+	flags.Bool("apparent-size", false, "count the apparent size of files instead of the size allocated on disk")
+	flags.Bool("no-dir-blocks", false, "do not count the size of directories themselves, only the files in them")
+	flags.Bool("du-compat", false, "count like GNU du: directory blocks included, hard links counted once, no file system skipped")
+	flags.String("hard-links", "first", "how a file with several hard links is counted. optional: first (the first path seen), split (evenly across its links)")
+}
+
+// addDisplayFlags adds the flags controlling how a tree is displayed.
+func addDisplayFlags(flags *pflag.FlagSet) {
+	flags.StringP("unit", "u", "M", "displayed units. optional: B(Bytes), K(KB), M(MB), G(GB), T(TB), A(auto)")
+	flags.Int64P("depth", "d", 1, "shows the depth of the tree directory structure")
+	flags.BoolP("all", "a", false, "display all directories, otherwise only display folders holding files")
+	flags.StringP("color", "c", "auto", "set color output mode. optional: auto, always, ignore")
+	flags.BoolP("recursion", "r", false, "automatically calculate directory depth, for recursively traversing all sub directories")
 }
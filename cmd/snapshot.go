@@ -0,0 +1,60 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"github.com/chenquan/diskusage/internal"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save the scanned tree to a snapshot file.",
+	Example: `1.Save a gzipped snapshot of /home: diskusage snapshot --dir /home --out home.json.gz
+2.Compare it with a later one: diskusage diff home.json.gz home-new.json.gz`,
+	Args: cobra.NoArgs,
+	RunE: internal.Snapshot,
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff old new",
+	Short: "Show the growth and shrinkage between two snapshots.",
+	Example: `1.Show what grew since the last snapshot: diskusage diff last-week.json.gz today.json.gz -d 3
+2.Include unchanged paths: diskusage diff old.json.gz new.json.gz -a`,
+	Args: cobra.ExactArgs(2),
+	RunE: internal.Diff,
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show the largest files of the whole tree.",
+	Example: `1.Show the 20 largest files under /var: diskusage top --dir /var
+2.Show the 5 largest logs in GB: diskusage top -n 5 -t log -u G`,
+	Args: cobra.NoArgs,
+	RunE: internal.Top,
+}
+
+func init() {
+	addScanFlags(snapshotCmd.Flags())
+	snapshotCmd.Flags().StringP("out", "o", "-", "snapshot file, gzipped when it ends with .gz, - for stdout")
+
+	addDisplayFlags(diffCmd.Flags())
+
+	addScanFlags(topCmd.Flags())
+	topCmd.Flags().IntP("number", "n", 20, "number of files shown")
+	topCmd.Flags().StringP("unit", "u", "M", "displayed units. optional: B(Bytes), K(KB), M(MB), G(GB), T(TB), A(auto)")
+
+	rootCmd.AddCommand(snapshotCmd, diffCmd, topCmd)
+}
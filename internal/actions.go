@@ -0,0 +1,368 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// removeFile deletes the file or directory at path.
+func removeFile(path string) error {
+	return os.RemoveAll(path)
+}
+
+// trashFile moves the file or directory at path to the trash, following the
+// FreeDesktop.org trash specification. The home trash is used when path is
+// on the same file system, otherwise the .Trash-$uid directory at the top of
+// the file system holding path.
+func trashFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	trash, err := homeTrash()
+	if err != nil {
+		return err
+	}
+
+	err = trashInto(trash, abs, abs)
+	if !isCrossDevice(err) {
+		return err
+	}
+
+	top, err := mountTop(abs)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return err
+	}
+
+	return trashInto(filepath.Join(top, ".Trash-"+strconv.Itoa(os.Getuid())), abs, rel)
+}
+
+func homeTrash() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// trashInto moves abs to the trash directory, recording originalPath in its
+// trashinfo file.
+func trashInto(trash, abs, originalPath string) error {
+	filesDir, infoDir := filepath.Join(trash, "files"), filepath.Join(trash, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: originalPath}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+
+	base := filepath.Base(abs)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = base + "." + strconv.Itoa(i)
+		}
+
+		// the trashinfo file is created exclusively first to reserve the name.
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = f.WriteString(info)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(abs, filepath.Join(filesDir, name))
+		}
+		if err != nil {
+			_ = os.Remove(infoPath)
+		}
+
+		return err
+	}
+}
+
+// mountTop returns the top directory of the file system holding path.
+func mountTop(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	dev, ok := device(info)
+	if !ok {
+		return "", errors.New("can not find the file system of " + path)
+	}
+
+	top := path
+	for {
+		parent := filepath.Dir(top)
+		if parent == top {
+			return top, nil
+		}
+
+		info, err := os.Lstat(parent)
+		if err != nil {
+			return "", err
+		}
+		if parentDev, _ := device(info); parentDev != dev {
+			return top, nil
+		}
+		top = parent
+	}
+}
+
+// moveFile moves the file or directory at path into the directory dir,
+// copying it when dir is on another file system, and returns its new path.
+// The new path is returned along with the error when the copy is complete
+// but path could not be removed.
+func moveFile(path, dir string) (string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", errors.New(dir + " is not a directory")
+	}
+
+	target := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Lstat(target); err == nil {
+		return "", errors.New(target + " already exists")
+	}
+
+	err = os.Rename(path, target)
+	if err == nil {
+		return target, nil
+	}
+	if !isCrossDevice(err) {
+		return "", err
+	}
+
+	if err := copyTree(path, target); err != nil {
+		_ = os.RemoveAll(target)
+		return "", err
+	}
+
+	return target, os.RemoveAll(path)
+}
+
+// copyTree copies the file or directory src to dst, keeping the modes,
+// modification times and symbolic links.
+func copyTree(src, dst string) error {
+	var dirs []string
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			// the directory stays writable until its entries are copied.
+			dirs = append(dirs, rel)
+			return os.Mkdir(target, 0o700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			err = copyFile(path, target, info.Mode().Perm())
+		default:
+			return errors.New("can not copy " + path)
+		}
+		if err != nil {
+			return err
+		}
+
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+
+	// the deepest directories come first, as copying into a directory
+	// changes its modification time.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		info, err := os.Stat(filepath.Join(src, dirs[i]))
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, dirs[i])
+		if err := os.Chmod(target, info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func isCrossDevice(err error) bool {
+	var linkErr *os.LinkError
+	return errors.As(err, &linkErr) && errors.Is(linkErr.Err, syscall.EXDEV)
+}
+
+// rescanFile returns the file left at path after an action on f failed part
+// way: f itself when it is a file still there, the directory scanned again
+// with rescan, or nil when it is gone.
+func rescanFile(path string, f *file, rescan func(dir string) (*file, error)) *file {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if !f.isDir {
+		// f may be attached at the target of a move as well.
+		left := *f
+		return &left
+	}
+
+	dir, err := rescan(path)
+	if err != nil {
+		return f
+	}
+	dir.name = f.name
+
+	return dir
+}
+
+// detach removes f from the tree, subtracting its sizes from its ancestors,
+// the last of which holds f.
+func detach(ancestors []*file, f *file) {
+	parent := ancestors[len(ancestors)-1]
+	for i, sub := range parent.sub {
+		if sub == f {
+			parent.sub = append(parent.sub[:i], parent.sub[i+1:]...)
+			break
+		}
+	}
+
+	resize(ancestors, f, -1)
+}
+
+// attach adds f to the tree, adding its sizes to its ancestors, the last of
+// which holds f.
+func attach(ancestors []*file, f *file) {
+	parent := ancestors[len(ancestors)-1]
+	parent.sub = append(parent.sub, f)
+
+	resize(ancestors, f, 1)
+}
+
+// resize adds the sizes and file counts of f to its ancestors, or subtracts
+// them when sign is -1, keeping their entries sorted by size.
+func resize(ancestors []*file, f *file, sign int64) {
+	for _, a := range ancestors {
+		a.disk += sign * f.disk
+		a.apparent += sign * f.apparent
+		a.count += sign * f.fileCount()
+		a.links += sign * f.links
+		a.setSize()
+	}
+
+	for _, a := range ancestors {
+		sort.SliceStable(a.sub, func(i, j int) bool { return a.sub[i].size > a.sub[j].size })
+	}
+}
+
+// lookup returns the directory at rel from root along with its ancestors,
+// or false when it is not part of the tree.
+func lookup(root *file, rel string) ([]*file, bool) {
+	ancestors := []*file{root}
+	if rel == "." {
+		return ancestors, true
+	}
+
+	dir := root
+	for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+		var next *file
+		for _, sub := range dir.sub {
+			if sub.name == name && sub.isDir {
+				next = sub
+				break
+			}
+		}
+		if next == nil {
+			return nil, false
+		}
+
+		dir = next
+		ancestors = append(ancestors, dir)
+	}
+
+	return ancestors, true
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrashInto(t *testing.T) {
+	trash, dir := filepath.Join(t.TempDir(), "Trash"), t.TempDir()
+	for _, name := range []string{"a b.txt", "other"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := trashInto(trash, path, "/home/user/a b.txt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(trash, "info", "a b.txt.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != "[Trash Info]" || lines[1] != "Path=/home/user/a%20b.txt" {
+		t.Fatalf("trashinfo = %q", data)
+	}
+	date, ok := strings.CutPrefix(lines[2], "DeletionDate=")
+	if _, err := time.Parse("2006-01-02T15:04:05", date); !ok || err != nil {
+		t.Errorf("DeletionDate = %q", lines[2])
+	}
+
+	// a name already in the trash gets a numbered one.
+	path := filepath.Join(dir, "nested", "a b.txt")
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := trashInto(trash, path, path); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"files/a b.txt", "files/a b.txt.2", "info/a b.txt.2.trashinfo", "files/other"} {
+		if _, err := os.Lstat(filepath.Join(trash, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := os.Lstat(path); err == nil {
+		t.Errorf("%s is still there", path)
+	}
+}
+
+func TestTrashIntoRollback(t *testing.T) {
+	trash := filepath.Join(t.TempDir(), "Trash")
+	missing := filepath.Join(t.TempDir(), "missing")
+	if err := trashInto(trash, missing, missing); err == nil {
+		t.Fatal("trashing a missing file succeeded")
+	}
+
+	entries, err := os.ReadDir(filepath.Join(trash, "info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%s left in the trash", entries[0].Name())
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	src, dst := filepath.Join(t.TempDir(), "src"), t.TempDir()
+	if err := os.Mkdir(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := moveFile(src, dst)
+	if err != nil || target != filepath.Join(dst, "src") {
+		t.Fatalf("moveFile = %q, %v", target, err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "a")); err != nil {
+		t.Error(err)
+	}
+
+	if err := os.Mkdir(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := moveFile(src, dst); err == nil {
+		t.Error("moving onto an existing file succeeded")
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("modes and symbolic links are not kept on windows")
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := []struct {
+		name string
+		mode os.FileMode
+	}{
+		{name: "", mode: os.ModeDir | 0o750},
+		{name: "dir", mode: os.ModeDir | 0o500},
+		{name: "dir/file", mode: 0o640},
+		{name: "run.sh", mode: 0o755},
+	}
+	// the files come before the directories holding them, which are
+	// read-only or would get a new modification time otherwise.
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		path := filepath.Join(src, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if f.mode.IsDir() {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.WriteFile(path, []byte(f.name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("dir/file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		path := filepath.Join(src, files[i].name)
+		if err := os.Chmod(path, files[i].mode.Perm()); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(src, "dir"), 0o755) })
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := copyTree(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(dst, "dir"), 0o755) })
+
+	for _, f := range files {
+		info, err := os.Lstat(filepath.Join(dst, f.name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if info.Mode() != f.mode {
+			t.Errorf("mode of %q = %v, want %v", f.name, info.Mode(), f.mode)
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("modification time of %q = %v, want %v", f.name, info.ModTime(), mtime)
+		}
+	}
+
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "dir/file" {
+		t.Errorf("link = %q, %v", link, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "dir", "file")); err != nil || string(data) != "dir/file" {
+		t.Errorf("dir/file = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,228 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	flag "github.com/spf13/pflag"
+)
+
+type (
+	// breakdown aggregates the counted files by a key, like their owner.
+	breakdown struct {
+		title  string
+		key    func(path string, info fs.FileInfo) string
+		mu     sync.Mutex
+		groups map[string]*breakdownGroup
+	}
+
+	breakdownGroup struct {
+		name  string
+		size  int64
+		count int64
+	}
+)
+
+var (
+	// breakdowns are the reports selected by --by, filled by find.
+	breakdowns []*breakdown
+
+	// archiveExts are the extensions grouped with the compression extension
+	// following them, like .tar in .tar.gz.
+	archiveExts     = map[string]bool{".tar": true}
+	compressionExts = map[string]bool{".gz": true, ".xz": true, ".bz2": true, ".zst": true}
+)
+
+func setBreakdowns(flags *flag.FlagSet) error {
+	by, err := flags.GetString("by")
+	if err != nil {
+		return err
+	}
+
+	switch by {
+	case "":
+	case "owner":
+		breakdowns = []*breakdown{
+			newBreakdown("User", func(_ string, info fs.FileInfo) string {
+				uid, _, ok := ownerIDs(info)
+				if !ok {
+					return "unknown"
+				}
+				return ownerName(lookupUser(uid), uid)
+			}),
+			newBreakdown("Group", func(_ string, info fs.FileInfo) string {
+				_, gid, ok := ownerIDs(info)
+				if !ok {
+					return "unknown"
+				}
+				return ownerName(lookupGroup(gid), gid)
+			}),
+		}
+	case "type":
+		breakdowns = []*breakdown{newBreakdown("Type", func(_ string, info fs.FileInfo) string {
+			return typeExt(info.Name())
+		})}
+	case "mime":
+		breakdowns = []*breakdown{newBreakdown("MIME", sniffType)}
+	default:
+		return errors.New("invalid by:" + by)
+	}
+
+	return nil
+}
+
+func newBreakdown(title string, key func(path string, info fs.FileInfo) string) *breakdown {
+	return &breakdown{title: title, key: key, groups: make(map[string]*breakdownGroup)}
+}
+
+// ownerName shows the id of a user or group along with its name.
+// typeExt returns the lower cased extension grouping a file in the type
+// report, or "(none)". Compressed archives like .tar.gz keep both parts,
+// other dots like the ones of dates or versions are part of the base name.
+func typeExt(name string) string {
+	name = strings.ToLower(strings.TrimLeft(name, "."))
+	ext := filepath.Ext(name)
+	if inner := filepath.Ext(strings.TrimSuffix(name, ext)); compressionExts[ext] && archiveExts[inner] {
+		ext = inner + ext
+	}
+	if ext == "" {
+		return "(none)"
+	}
+
+	return ext
+}
+
+func ownerName(name string, id uint32) string {
+	s := strconv.FormatUint(uint64(id), 10)
+	if name == s {
+		return s
+	}
+
+	return fmt.Sprintf("%s (%s)", name, s)
+}
+
+// sniffType detects the MIME type of a file from its first bytes.
+func sniffType(path string, info fs.FileInfo) string {
+	if !info.Mode().IsRegular() {
+		return fileType(info.Mode())
+	}
+	if info.Size() == 0 {
+		return "empty"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "unknown"
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "unknown"
+	}
+
+	mime, _, _ := strings.Cut(http.DetectContentType(buf[:n]), ";")
+
+	return mime
+}
+
+// account adds a counted file to the breakdowns.
+func account(path string, info fs.FileInfo, f *file) {
+	for _, b := range breakdowns {
+		key := b.key(path, info)
+
+		b.mu.Lock()
+		group, ok := b.groups[key]
+		if !ok {
+			group = &breakdownGroup{name: key}
+			b.groups[key] = group
+		}
+		group.size += f.size
+		group.count++
+		b.mu.Unlock()
+	}
+}
+
+// printBreakdowns writes a table per breakdown, the largest groups first. The
+// percentages are relative to the files counted, leaving out the size of the
+// directories themselves.
+func printBreakdowns(dir, unit string) {
+	for i, b := range breakdowns {
+		if i > 0 {
+			colorPrintln()
+		}
+
+		groups := make([]*breakdownGroup, 0, len(b.groups))
+		var totalSize, totalCount int64
+		for _, group := range b.groups {
+			groups = append(groups, group)
+			totalSize += group.size
+			totalCount += group.count
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			if groups[i].size != groups[j].size {
+				return groups[i].size > groups[j].size
+			}
+			return groups[i].name < groups[j].name
+		})
+
+		t := table.NewWriter()
+		t.SetStyle(table.StyleLight)
+		t.SetTitle(dir)
+		t.AppendHeader(table.Row{b.title, "Size", "Files", "Average", "Usage"})
+		for _, group := range groups {
+			t.AppendRow(table.Row{group.name, formatSize(unit, group.size), group.count,
+				formatSize(unit, average(group.size, group.count)),
+				fmt.Sprintf("%0.1f%%", usageRate(group.size, totalSize))})
+		}
+		t.AppendFooter(table.Row{"Total", formatSize(unit, totalSize), totalCount,
+			formatSize(unit, average(totalSize, totalCount)), "100.0%"})
+		t.SetColumnConfigs([]table.ColumnConfig{
+			{Number: 2, Align: text.AlignRight, AlignFooter: text.AlignRight},
+			{Number: 3, Align: text.AlignRight, AlignFooter: text.AlignRight},
+			{Number: 4, Align: text.AlignRight, AlignFooter: text.AlignRight},
+			{Number: 5, Align: text.AlignRight, AlignFooter: text.AlignRight},
+		})
+
+		colorPrintln(t.Render())
+	}
+}
+
+func formatSize(unit string, size int64) string {
+	val, reduceUnit := getReduce(unit, size)
+	return fmt.Sprintf("%0.1f%s", val, reduceUnit)
+}
+
+func average(size, count int64) int64 {
+	if count == 0 {
+		return 0
+	}
+
+	return size / count
+}
@@ -0,0 +1,28 @@
+package internal
+
+import "testing"
+
+func TestTypeExt(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "app.2024-10-01.log", want: ".log"},
+		{name: "app.2024-10-02.log", want: ".log"},
+		{name: "jquery-3.6.0.min.js", want: ".js"},
+		{name: "Movie.MP4", want: ".mp4"},
+		{name: "backup.tar.gz", want: ".tar.gz"},
+		{name: "rootfs.TAR.ZST", want: ".tar.zst"},
+		{name: "notes.txt.gz", want: ".gz"},
+		{name: "v1.2.tar", want: ".tar"},
+		{name: ".bashrc", want: "(none)"},
+		{name: ".config.json", want: ".json"},
+		{name: "Makefile", want: "(none)"},
+	}
+
+	for _, test := range tests {
+		if got := typeExt(test.name); got != test.want {
+			t.Errorf("typeExt(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+
+	"github.com/jedib0t/go-pretty/v6/list"
+)
+
+var csvHeader = []string{"path", "type", "size", "human_size", "percent"}
+
+// printCSV writes one row per displayed file with its path relative to the
+// scanned directory. The rows are the same as those of the tree output.
+func printCSV(files []*file, depth int64, unit string, totalSize int64, recursion, tsv bool) error {
+	infoFiles := buildInfoFile(list.NewWriter(), files, "", 0, depth, unit, totalSize, recursion)
+
+	writer := csv.NewWriter(out)
+	if tsv {
+		writer.Comma = '\t'
+	}
+
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, info := range infoFiles {
+		fileType := "file"
+		if info.isDir {
+			fileType = "dir"
+		}
+
+		err := writer.Write([]string{
+			info.path,
+			fileType,
+			strconv.FormatInt(info.bytes, 10),
+			fmt.Sprintf("%0.1f%s", info.size, info.uint),
+			fmt.Sprintf("%0.2f", info.usageRate),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
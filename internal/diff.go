@@ -0,0 +1,260 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/list"
+	"github.com/spf13/cobra"
+)
+
+type (
+	diffFile struct {
+		sub     []*diffFile
+		name    string
+		isDir   bool
+		oldSize int64
+		newSize int64
+		added   bool
+		removed bool
+	}
+
+	diffInfo struct {
+		delta   string
+		percent string
+		change  int64
+	}
+)
+
+// Diff compares two snapshots and prints a tree of the growth and shrinkage
+// of every path.
+func Diff(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	depth, err := flags.GetInt64("depth")
+	if err != nil {
+		return err
+	}
+
+	unit, err := getUnit(flags)
+	if err != nil {
+		return err
+	}
+
+	all, err := flags.GetBool("all")
+	if err != nil {
+		return err
+	}
+
+	err = handleColor(flags)
+	if err != nil {
+		return err
+	}
+
+	recursion, err := flags.GetBool("recursion")
+	if err != nil {
+		return err
+	}
+
+	oldSnapshot, err := readSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+
+	newSnapshot, err := readSnapshot(args[1])
+	if err != nil {
+		return err
+	}
+
+	files := diffFiles(oldSnapshot.Files, newSnapshot.Files)
+	total := diffFile{oldSize: oldSnapshot.Size, newSize: newSnapshot.Size}
+	totalInfo := buildDiffInfo(&total, unit)
+
+	header := fmt.Sprintf("Total: %s %s\t%s", totalInfo.delta, totalInfo.percent, color.HiGreenString(newSnapshot.Root))
+	colorPrintln(header)
+	colorPrintln(fmt.Sprintf("%s → %s", oldSnapshot.Timestamp.Format(time.DateTime), newSnapshot.Timestamp.Format(time.DateTime)))
+	colorPrintln(strings.Repeat("─", len(header)+2))
+
+	l := list.NewWriter()
+	l.SetStyle(list.StyleConnectedLight)
+	infos := buildDiffTree(l, files, 0, depth, unit, recursion, all)
+	printDiffTree(l.Render(), infos)
+
+	fmt.Print(out.String())
+
+	return nil
+}
+
+// diffFiles matches the files of two snapshots by name. The result is sorted
+// by the absolute size change in descending order.
+func diffFiles(oldFiles, newFiles []*snapshotFile) []*diffFile {
+	oldMap := make(map[string]*snapshotFile, len(oldFiles))
+	for _, f := range oldFiles {
+		oldMap[f.Name] = f
+	}
+
+	files := make([]*diffFile, 0, len(newFiles))
+	for _, newFile := range newFiles {
+		f := &diffFile{name: newFile.Name, isDir: newFile.IsDir, newSize: newFile.Size}
+		oldFile, ok := oldMap[newFile.Name]
+		if ok {
+			delete(oldMap, newFile.Name)
+			f.oldSize = oldFile.Size
+			f.sub = diffFiles(oldFile.Sub, newFile.Sub)
+		} else {
+			f.added = true
+			f.sub = diffFiles(nil, newFile.Sub)
+		}
+		files = append(files, f)
+	}
+
+	for _, oldFile := range oldFiles {
+		if _, ok := oldMap[oldFile.Name]; !ok {
+			continue
+		}
+
+		files = append(files, &diffFile{
+			sub:     diffFiles(oldFile.Sub, nil),
+			name:    oldFile.Name,
+			isDir:   oldFile.IsDir,
+			oldSize: oldFile.Size,
+			removed: true,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		di, dj := abs(files[i].delta()), abs(files[j].delta())
+		if di != dj {
+			return di > dj
+		}
+		return files[i].name < files[j].name
+	})
+
+	return files
+}
+
+func (f *diffFile) delta() int64 {
+	return f.newSize - f.oldSize
+}
+
+// changed reports whether f or any file of its tree changed size, was added
+// or was removed, even when the changes cancel out in the size of f.
+func (f *diffFile) changed() bool {
+	if f.delta() != 0 || f.added || f.removed {
+		return true
+	}
+	for _, sub := range f.sub {
+		if sub.changed() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func buildDiffTree(l list.Writer, files []*diffFile, n, depth int64, unit string, recursion, all bool) []diffInfo {
+	if n == depth && !recursion {
+		return nil
+	}
+
+	var infos []diffInfo
+	for _, f := range files {
+		if !f.changed() && !all {
+			continue
+		}
+
+		infos = append(infos, buildDiffInfo(f, unit))
+
+		name := f.name
+		if f.isDir {
+			name = color.HiGreenString(name)
+		}
+		l.AppendItem(name)
+
+		if f.isDir {
+			l.Indent()
+			infos = append(infos, buildDiffTree(l, f.sub, n+1, depth, unit, recursion, all)...)
+			l.UnIndent()
+		}
+	}
+
+	return infos
+}
+
+func buildDiffInfo(f *diffFile, unit string) diffInfo {
+	delta := f.delta()
+	val, reduceUnit := getReduce(unit, abs(delta))
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+	}
+
+	var percent string
+	switch {
+	case f.removed:
+		percent = "gone"
+	case f.added, f.oldSize == 0 && delta != 0:
+		percent = "new"
+	case f.oldSize == 0:
+		percent = "+0.0%"
+	default:
+		percent = fmt.Sprintf("%+0.1f%%", float64(delta)/float64(f.oldSize)*100)
+	}
+
+	return diffInfo{
+		delta:   fmt.Sprintf("%s%0.1f%s", sign, val, reduceUnit),
+		percent: percent,
+		change:  delta,
+	}
+}
+
+func printDiffTree(content string, infos []diffInfo) {
+	deltaLen, percentLen := 0, 0
+	for _, info := range infos {
+		deltaLen = max(deltaLen, len(info.delta))
+		percentLen = max(percentLen, len(info.percent))
+	}
+
+	format := " %" + strconv.Itoa(deltaLen) + "s %" + strconv.Itoa(percentLen) + "s"
+	for i, line := range strings.Split(content, "\n") {
+		if i >= len(infos) {
+			continue
+		}
+
+		info := infos[i]
+		str := fmt.Sprintf(format, info.delta, info.percent)
+		switch {
+		case info.change > 0:
+			str = color.HiRedString(str)
+		case info.change < 0:
+			str = color.HiGreenString(str)
+		}
+
+		colorPrintln(str, line)
+	}
+	colorPrintln()
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
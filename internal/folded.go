@@ -0,0 +1,47 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// foldedReplacer keeps frame names from breaking the collapsed stack syntax,
+// where frames are separated by ';' and every stack takes one line.
+var foldedReplacer = strings.NewReplacer(";", "_", "\n", "_", "\r", "_")
+
+// printFolded writes the whole tree of root as collapsed stack lines
+// "root;dir;file size", as read by flamegraph.pl, speedscope and inferno.
+// Every file takes a line, as does every directory with its own size, and
+// the entries without any usage are skipped.
+func printFolded(root *file) {
+	writeFolded(foldedReplacer.Replace(filepath.Base(root.name)), root)
+}
+
+func writeFolded(stack string, f *file) {
+	if size := f.ownSize(); size > 0 {
+		_, _ = fmt.Fprintf(out, "%s %d\n", stack, size)
+	}
+
+	for _, sub := range f.sub {
+		if sub.size <= 0 {
+			continue
+		}
+
+		writeFolded(stack+";"+foldedReplacer.Replace(sub.name), sub)
+	}
+}
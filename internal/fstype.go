@@ -0,0 +1,61 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"errors"
+
+	flag "github.com/spf13/pflag"
+)
+
+// fileSystemGroups are the aliases accepted by --skip-fs for several file
+// system classes at once.
+var fileSystemGroups = map[string][]string{
+	"pseudo":  {"proc", "sysfs", "cgroup", "devtmpfs", "debugfs", "tracefs"},
+	"network": {"nfs", "cifs", "fuse"},
+}
+
+// skipFileSystems holds the file system classes that are not descended into.
+var skipFileSystems = map[string]bool{}
+
+func setSkipFileSystems(flags *flag.FlagSet) error {
+	classes, err := flags.GetStringSlice("skip-fs")
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, group := range fileSystemGroups {
+		for _, class := range group {
+			known[class] = true
+		}
+	}
+
+	for _, class := range classes {
+		if group, ok := fileSystemGroups[class]; ok {
+			for _, c := range group {
+				skipFileSystems[c] = true
+			}
+			continue
+		}
+
+		if !known[class] {
+			return errors.New("invalid file system class:" + class)
+		}
+		skipFileSystems[class] = true
+	}
+
+	return nil
+}
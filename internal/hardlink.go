@@ -0,0 +1,99 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	// hardLinkFirst counts a hard linked file under the first path seen.
+	// The scan is concurrent, so which of the paths comes first may change
+	// between runs.
+	hardLinkFirst = "first"
+	// hardLinkSplit divides the size of a hard linked file evenly between
+	// its links. Links outside the scanned directory keep their share.
+	hardLinkSplit = "split"
+)
+
+type (
+	fileKey struct {
+		dev uint64
+		ino uint64
+	}
+
+	// fileLink identifies a file with several hard links, along with its
+	// whole sizes, of which each link may only count a part.
+	fileLink struct {
+		key      fileKey
+		nlink    uint64
+		disk     int64
+		apparent int64
+	}
+)
+
+var (
+	hardLinkMode = hardLinkFirst
+	hardLinksMu  sync.Mutex
+	hardLinks    = map[fileKey]struct{}{}
+)
+
+func setHardLinks(flags *flag.FlagSet) error {
+	mode, err := flags.GetString("hard-links")
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case hardLinkFirst, hardLinkSplit:
+		hardLinkMode = mode
+		return nil
+	default:
+		return errors.New("invalid hard links mode:" + mode)
+	}
+}
+
+// newFileLink returns the link of a file with several hard links, or nil.
+func newFileLink(info os.FileInfo, disk, apparent int64) *fileLink {
+	key, nlink, ok := inode(info)
+	if !ok || nlink <= 1 {
+		return nil
+	}
+
+	return &fileLink{key: key, nlink: nlink, disk: disk, apparent: apparent}
+}
+
+// counted returns the part of the allocated and apparent sizes counted for
+// this link of the file, so that every inode is only counted once over the
+// whole scan.
+func (l *fileLink) counted() (int64, int64) {
+	if hardLinkMode == hardLinkSplit && l.nlink > 1 {
+		return l.disk / int64(l.nlink), l.apparent / int64(l.nlink)
+	}
+
+	hardLinksMu.Lock()
+	defer hardLinksMu.Unlock()
+
+	if _, ok := hardLinks[l.key]; ok {
+		return 0, 0
+	}
+	hardLinks[l.key] = struct{}{}
+
+	return l.disk, l.apparent
+}
@@ -0,0 +1,238 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"math"
+	"path"
+	"time"
+)
+
+const (
+	treemapWidth  = 1200
+	treemapHeight = 700
+	// treemapHeader is the space kept at the top of a directory box for its
+	// label, treemapPadding the gap between a directory and its children.
+	treemapHeader  = 16
+	treemapPadding = 2
+)
+
+var (
+	//go:embed report.html.tmpl
+	reportTemplate string
+	reportTmpl     = template.Must(template.New("report").Parse(reportTemplate))
+)
+
+type (
+	htmlReport struct {
+		Dir     string
+		Total   string
+		Created string
+		Width   int
+		Height  int
+		Boxes   []htmlBox
+		Nodes   []*htmlNode
+	}
+
+	htmlBox struct {
+		X, Y, W, H float64
+		Label      string
+		Title      string
+		Color      template.CSS
+		IsDir      bool
+	}
+
+	htmlNode struct {
+		Name     string
+		IsDir    bool
+		Size     string
+		Rate     string
+		Children []*htmlNode
+	}
+
+	rect struct {
+		x, y, w, h float64
+	}
+)
+
+// printHTML writes a self-contained HTML page holding a squarified treemap and
+// a collapsible tree of the files marked by markPrint.
+func printHTML(dir string, files []*file, depth int64, unit string, totalSize int64, recursion bool) error {
+	val, reduceUnit := getReduce(unit, totalSize)
+	report := htmlReport{
+		Dir:     dir,
+		Total:   fmt.Sprintf("%0.3f%s", val, reduceUnit),
+		Created: time.Now().Format(time.RFC1123),
+		Width:   treemapWidth,
+		Height:  treemapHeight,
+		Nodes:   buildHTMLNode(files, 0, depth, unit, totalSize, recursion),
+	}
+	report.Boxes = layoutTreemap(nil, files, "", rect{w: treemapWidth, h: treemapHeight},
+		0, depth, 0, unit, totalSize, recursion)
+
+	return reportTmpl.Execute(out, report)
+}
+
+func buildHTMLNode(files []*file, n, depth int64, unit string, totalSize int64, recursion bool) []*htmlNode {
+	if n == depth && !recursion {
+		return nil
+	}
+
+	var nodes []*htmlNode
+	for _, f := range files {
+		if !f.print {
+			continue
+		}
+
+		val, reduceUnit := getReduce(unit, f.size)
+		nodes = append(nodes, &htmlNode{
+			Name:     f.name,
+			IsDir:    f.isDir,
+			Size:     fmt.Sprintf("%0.1f%s", val, reduceUnit),
+			Rate:     fmt.Sprintf("%0.1f%%", usageRate(f.size, totalSize)),
+			Children: buildHTMLNode(f.sub, n+1, depth, unit, totalSize, recursion),
+		})
+	}
+
+	return nodes
+}
+
+// layoutTreemap appends a box for every file inside r and recurses into the
+// directories that are large enough to hold a label and their children.
+func layoutTreemap(boxes []htmlBox, files []*file, parent string, r rect, n, depth int64, hue float64,
+	unit string, totalSize int64, recursion bool) []htmlBox {
+	if n == depth && !recursion {
+		return boxes
+	}
+
+	var (
+		shown []*file
+		sizes []float64
+		sum   float64
+	)
+	for _, f := range files {
+		if !f.print || f.size <= 0 {
+			continue
+		}
+		shown = append(shown, f)
+		sizes = append(sizes, float64(f.size))
+		sum += float64(f.size)
+	}
+	if len(shown) == 0 || r.w < 1 || r.h < 1 {
+		return boxes
+	}
+
+	area := r.w * r.h
+	for i := range sizes {
+		sizes[i] = sizes[i] / sum * area
+	}
+
+	for i, box := range squarify(sizes, r) {
+		f := shown[i]
+		filePath := path.Join(parent, f.name)
+		fileHue := hue
+		if n == 0 {
+			fileHue = math.Mod(float64(i)*137.5, 360)
+		}
+
+		val, reduceUnit := getReduce(unit, f.size)
+		size := fmt.Sprintf("%0.1f%s", val, reduceUnit)
+		boxes = append(boxes, htmlBox{
+			X:     box.x,
+			Y:     box.y,
+			W:     box.w,
+			H:     box.h,
+			Label: f.name + " " + size,
+			Title: fmt.Sprintf("%s\n%s %0.1f%%", filePath, size, usageRate(f.size, totalSize)),
+			Color: template.CSS(fmt.Sprintf("hsl(%.0f, 55%%, %d%%)", fileHue, max(40, 80-int(n)*8))),
+			IsDir: f.isDir,
+		})
+
+		if f.isDir && box.w > 4*treemapPadding && box.h > treemapHeader+2*treemapPadding {
+			inner := rect{
+				x: box.x + treemapPadding,
+				y: box.y + treemapHeader,
+				w: box.w - 2*treemapPadding,
+				h: box.h - treemapHeader - treemapPadding,
+			}
+			boxes = layoutTreemap(boxes, f.sub, filePath, inner, n+1, depth, fileHue, unit, totalSize, recursion)
+		}
+	}
+
+	return boxes
+}
+
+// squarify splits r into rectangles with the given areas using the squarified
+// treemap algorithm of Bruls, Huizing and van Wijk. The areas must be sorted in
+// descending order and add up to the area of r.
+func squarify(areas []float64, r rect) []rect {
+	rects := make([]rect, 0, len(areas))
+	for len(areas) > 0 {
+		side := math.Min(r.w, r.h)
+		n := 1
+		for n < len(areas) && worstRatio(areas[:n+1], side) <= worstRatio(areas[:n], side) {
+			n++
+		}
+
+		row := areas[:n]
+		rowArea := 0.0
+		for _, a := range row {
+			rowArea += a
+		}
+
+		if r.w >= r.h {
+			width := rowArea / r.h
+			y := r.y
+			for _, a := range row {
+				height := a / width
+				rects = append(rects, rect{x: r.x, y: y, w: width, h: height})
+				y += height
+			}
+			r.x += width
+			r.w -= width
+		} else {
+			height := rowArea / r.w
+			x := r.x
+			for _, a := range row {
+				width := a / height
+				rects = append(rects, rect{x: x, y: r.y, w: width, h: height})
+				x += width
+			}
+			r.y += height
+			r.h -= height
+		}
+
+		areas = areas[n:]
+	}
+
+	return rects
+}
+
+// worstRatio returns the highest aspect ratio of a row of areas laid along a
+// side of the given length.
+func worstRatio(row []float64, side float64) float64 {
+	sum, maxArea, minArea := 0.0, 0.0, math.MaxFloat64
+	for _, a := range row {
+		sum += a
+		maxArea = math.Max(maxArea, a)
+		minArea = math.Min(minArea, a)
+	}
+
+	side2, sum2 := side*side, sum*sum
+	return math.Max(side2*maxArea/sum2, sum2/(side2*minArea))
+}
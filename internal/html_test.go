@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSquarify(t *testing.T) {
+	r := rect{w: 600, h: 400}
+	areas := []float64{60000, 60000, 40000, 30000, 20000, 20000, 10000}
+
+	rects := squarify(areas, r)
+	if len(rects) != len(areas) {
+		t.Fatalf("got %d rects, want %d", len(rects), len(areas))
+	}
+
+	for i, got := range rects {
+		if math.Abs(got.w*got.h-areas[i]) > 1e-6 {
+			t.Errorf("rect %d has area %f, want %f", i, got.w*got.h, areas[i])
+		}
+		if got.x < -1e-6 || got.y < -1e-6 || got.x+got.w > r.w+1e-6 || got.y+got.h > r.h+1e-6 {
+			t.Errorf("rect %d %+v is outside of %+v", i, got, r)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// ignoreFileNames are the ignore files honored by --ignore-files.
+var ignoreFileNames = []string{".gitignore", ".duignore"}
+
+var (
+	// excludeRules holds the patterns of --exclude and --exclude-from, which
+	// apply to the whole scan.
+	excludeRules *ignoreRules
+	// honorIgnoreFiles makes find read the ignore files of every directory.
+	honorIgnoreFiles bool
+)
+
+type (
+	// ignoreRules are the patterns of an ignore file, applying to the
+	// directory holding it and below, on top of the rules of its parents.
+	ignoreRules struct {
+		parent   *ignoreRules
+		base     string
+		patterns []ignorePattern
+	}
+
+	ignorePattern struct {
+		re      *regexp.Regexp
+		negate  bool
+		dirOnly bool
+	}
+)
+
+func setExcludes(flags *flag.FlagSet) error {
+	excludes, err := flags.GetStringArray("exclude")
+	if err != nil {
+		return err
+	}
+
+	excludeFrom, err := flags.GetString("exclude-from")
+	if err != nil {
+		return err
+	}
+
+	honorIgnoreFiles, err = flags.GetBool("ignore-files")
+	if err != nil {
+		return err
+	}
+
+	rules := &ignoreRules{}
+	for _, exclude := range excludes {
+		rules.add(exclude)
+	}
+
+	if excludeFrom != "" {
+		f, err := os.Open(excludeFrom)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := rules.read(f); err != nil {
+			return err
+		}
+	}
+
+	if len(rules.patterns) > 0 {
+		excludeRules = rules
+	}
+
+	return nil
+}
+
+// readIgnoreFiles returns the rules for the directory dir, at rel from the
+// scanned directory, adding the patterns of the ignore files found in it.
+func readIgnoreFiles(parent *ignoreRules, dir, rel string, entries []os.DirEntry) *ignoreRules {
+	if !honorIgnoreFiles {
+		return parent
+	}
+
+	rules := &ignoreRules{parent: parent, base: rel}
+	for _, entry := range entries {
+		for _, name := range ignoreFileNames {
+			if entry.Name() != name || entry.IsDir() {
+				continue
+			}
+
+			f, err := os.Open(filepath.Join(dir, name))
+			if err != nil {
+				addScanError(err)
+				continue
+			}
+			if err := rules.read(f); err != nil {
+				addScanError(err)
+			}
+			_ = f.Close()
+		}
+	}
+
+	if len(rules.patterns) == 0 {
+		return parent
+	}
+
+	return rules
+}
+
+func (r *ignoreRules) read(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		r.add(scanner.Text())
+	}
+
+	return scanner.Err()
+}
+
+// add compiles a pattern following the syntax of gitignore.
+func (r *ignoreRules) add(line string) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	var pattern ignorePattern
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return
+	}
+
+	// a pattern without a slash matches a name at any level, otherwise it is
+	// relative to the directory of the ignore file.
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	line = strings.TrimPrefix(line, "/")
+
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return
+	}
+	pattern.re = re
+
+	r.patterns = append(r.patterns, pattern)
+}
+
+// ignored reports whether the path rel from the scanned directory is
+// ignored. The last matching pattern wins, and the patterns of a directory
+// take precedence over those of its parents.
+func (r *ignoreRules) ignored(rel string, isDir bool) bool {
+	for rules := r; rules != nil; rules = rules.parent {
+		name := rel
+		if rules.base != "" {
+			if !strings.HasPrefix(rel, rules.base+"/") {
+				continue
+			}
+			name = rel[len(rules.base)+1:]
+		}
+
+		for i := len(rules.patterns) - 1; i >= 0; i-- {
+			pattern := rules.patterns[i]
+			if pattern.dirOnly && !isDir {
+				continue
+			}
+			if pattern.re.MatchString(name) {
+				return !pattern.negate
+			}
+		}
+	}
+
+	return false
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return sb.String()
+}
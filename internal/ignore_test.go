@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	root := &ignoreRules{}
+	for _, line := range []string{
+		"# comment",
+		"node_modules/",
+		"*.log",
+		"!keep.log",
+		"/build",
+		"docs/**/*.pdf",
+	} {
+		root.add(line)
+	}
+
+	sub := &ignoreRules{parent: root, base: "app"}
+	sub.add("tmp")
+	sub.add("!debug.log")
+
+	tests := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{rel: "node_modules", isDir: true, ignored: true},
+		{rel: "a/node_modules", isDir: true, ignored: true},
+		{rel: "node_modules", isDir: false, ignored: false},
+		{rel: "error.log", ignored: true},
+		{rel: "a/b/error.log", ignored: true},
+		{rel: "keep.log", ignored: false},
+		{rel: "build", isDir: true, ignored: true},
+		{rel: "a/build", isDir: true, ignored: false},
+		{rel: "docs/manual.pdf", ignored: true},
+		{rel: "docs/a/b/manual.pdf", ignored: true},
+		{rel: "manual.pdf", ignored: false},
+		{rel: "app/tmp", isDir: true, ignored: true},
+		{rel: "tmp", isDir: true, ignored: false},
+		{rel: "app/debug.log", ignored: false},
+		{rel: "app/error.log", ignored: true},
+	}
+
+	for _, test := range tests {
+		if got := sub.ignored(test.rel, test.isDir); got != test.ignored {
+			t.Errorf("ignored(%q, %v) = %v, want %v", test.rel, test.isDir, got, test.ignored)
+		}
+	}
+}
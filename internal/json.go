@@ -0,0 +1,92 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+)
+
+type (
+	jsonTree struct {
+		Path     string      `json:"path"`
+		Size     int64       `json:"size"`
+		Children []*jsonFile `json:"children"`
+	}
+
+	jsonFile struct {
+		Name         string      `json:"name"`
+		IsDir        bool        `json:"isDir"`
+		Size         int64       `json:"size"`
+		DiskSize     int64       `json:"diskSize"`
+		ApparentSize int64       `json:"apparentSize"`
+		UsageRate    float64     `json:"usageRate"`
+		ReadError    bool        `json:"readError,omitempty"`
+		SubError     bool        `json:"subError,omitempty"`
+		Children     []*jsonFile `json:"children,omitempty"`
+	}
+)
+
+// printJSON writes the files marked by markPrint as a nested JSON document,
+// cutting the tree at the same depth as buildInfoFile.
+func printJSON(dir string, files []*file, depth, totalSize int64, recursion bool) error {
+	tree := jsonTree{
+		Path:     dir,
+		Size:     totalSize,
+		Children: buildJSONFile(files, 0, depth, totalSize, recursion),
+	}
+	if tree.Children == nil {
+		tree.Children = []*jsonFile{}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(tree)
+}
+
+func buildJSONFile(files []*file, n, depth, totalSize int64, recursion bool) []*jsonFile {
+	if n == depth && !recursion {
+		return nil
+	}
+
+	var jsonFiles []*jsonFile
+	for _, f := range files {
+		if !f.print {
+			continue
+		}
+
+		jsonFiles = append(jsonFiles, &jsonFile{
+			Name:         f.name,
+			IsDir:        f.isDir,
+			Size:         f.size,
+			DiskSize:     f.disk,
+			ApparentSize: f.apparent,
+			UsageRate:    usageRate(f.size, totalSize),
+			ReadError:    f.readError,
+			SubError:     f.subError,
+			Children:     buildJSONFile(f.sub, n+1, depth, totalSize, recursion),
+		})
+	}
+
+	return jsonFiles
+}
+
+func usageRate(size, totalSize int64) float64 {
+	if totalSize == 0 {
+		return 0
+	}
+
+	return float64(size) / float64(totalSize) * 100
+}
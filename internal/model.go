@@ -2,11 +2,17 @@ package internal
 
 import (
 	"fmt"
-	"github.com/charmbracelet/bubbles/viewport"
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 var (
@@ -21,80 +27,824 @@ var (
 		b.Left = "┤"
 		return titleStyle.Copy().BorderStyle(b)
 	}()
+
+	dirStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	markStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	cursorStyle = lipgloss.NewStyle().Reverse(true)
+	helpStyle   = lipgloss.NewStyle().Faint(true)
+	dialogStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1, 2)
+)
+
+// barWidth is the number of cells of the usage bar.
+const barWidth = 10
+
+var (
+	// sortOrders are the orders of the entries, switched with s. Sizes,
+	// counts and times are sorted in descending order, names in ascending
+	// order.
+	sortOrders = []string{"size", "name", "count", "mtime"}
+	// viewUnits are the units switched with u.
+	viewUnits = []string{"B", "K", "M", "G", "T", "A"}
+	// busyKeys are the keys ignored while an action is running.
+	busyKeys = []string{"right", "l", "enter", "backspace", "d", "delete", "t", "m"}
+)
+
+type (
+	// model browses the scanned tree. The entries of the current directory
+	// are listed, along with the entries of the directories expanded in
+	// place.
+	model struct {
+		root *file
+		// dirs is the path from the root to the current directory.
+		dirs     []*file
+		expanded map[*file]bool
+		rows     []row
+		cursor   int
+		offset   int
+
+		// scanning is set until the scan is done, root holding the top level
+		// subtrees scanned so far.
+		scanning bool
+		spinner  spinner.Model
+		err      error
+
+		unit      string
+		all       bool
+		directory bool
+
+		sortOrder   int
+		hidePercent bool
+		hideBar     bool
+
+		// search is the filter typed after /, match its compiled pattern and
+		// matches the part of the tree of each file of the current directory
+		// matching it.
+		search     textinput.Model
+		searchMode int
+		searchErr  error
+		match      matcher
+		matches    map[*file]matched
+
+		// action is the action on the selected file waiting for a
+		// confirmation, or for its destination when input is focused.
+		// rescan scans a directory again after an action failed part way,
+		// and is nil for a tree read from a dump, whose paths may not be local
+		// and can not be acted on.
+		action string
+		rescan func(dir string) (*file, error)
+		input  textinput.Model
+		busy   bool
+		status string
+
+		width  int
+		height int
+		ready  bool
+	}
+
+	row struct {
+		f *file
+		// depth is the indentation below the current directory, and parent
+		// the index of the row of the expanded directory holding f, or -1.
+		depth  int
+		parent int
+	}
+
+	// partialMsg reports a top level subtree whose scan is done.
+	partialMsg struct {
+		f *file
+	}
+
+	// scanDoneMsg reports the end of the scan with the complete tree.
+	scanDoneMsg struct {
+		root *file
+		err  error
+	}
+
+	// actionMsg reports the end of an action on the file f, held by the last
+	// of ancestors.
+	actionMsg struct {
+		action    string
+		f         *file
+		ancestors []*file
+		// target is where f was moved to, even when the action failed after
+		// copying it, and rescanned is the file left at the path of f after a
+		// failure, or nil when it is gone.
+		target    string
+		rescanned *file
+		err       error
+	}
 )
 
-type model struct {
-	content  string
-	ready    bool
-	viewport viewport.Model
+func newModel(root *file, unit string, all, directory bool) model {
+	input := textinput.New()
+	input.Prompt = "Move to: "
+	input.Placeholder = "directory"
+
+	search := textinput.New()
+	search.Prompt = "/" + searchModes[0] + ": "
+
+	m := model{
+		root:      root,
+		dirs:      []*file{root},
+		expanded:  make(map[*file]bool),
+		unit:      unit,
+		all:       all,
+		directory: directory,
+		input:     input,
+		search:    search,
+	}
+	m.buildRows()
+
+	return m
 }
 
 func (m model) Init() tea.Cmd {
+	if m.scanning {
+		return m.spinner.Tick
+	}
+
 	return nil
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var (
-		cmd  tea.Cmd
-		cmds []tea.Cmd
-	)
-
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if k := msg.String(); k == "ctrl+c" || k == "q" || k == "esc" {
+		if m.action != "" {
+			return m.updateAction(msg)
+		}
+		if m.search.Focused() {
+			return m.updateSearch(msg)
+		}
+		// the directories can not change while an action may detach one.
+		if m.busy && slices.Contains(busyKeys, msg.String()) {
+			return m, nil
+		}
+
+		m.status = ""
+		switch msg.String() {
+		case "esc":
+			if m.match != nil {
+				m.search.SetValue("")
+				m.applySearch()
+				break
+			}
+			return m, tea.Quit
+		case "ctrl+c", "q":
 			return m, tea.Quit
+		case "/":
+			return m, m.search.Focus()
+		case "up", "k":
+			m.move(-1)
+		case "down", "j":
+			m.move(1)
+		case "pgup":
+			m.move(-m.listHeight())
+		case "pgdown":
+			m.move(m.listHeight())
+		case "home", "g":
+			m.move(-len(m.rows))
+		case "end", "G":
+			m.move(len(m.rows))
+		case "right", "l":
+			m.expand()
+		case "enter":
+			m.open()
+		case "left", "h":
+			m.collapse()
+		case "backspace":
+			m.up()
+		case "s":
+			m.sortOrder = (m.sortOrder + 1) % len(sortOrders)
+			m.rebuild()
+		case "u":
+			m.unit = viewUnits[(slices.Index(viewUnits, m.unit)+1)%len(viewUnits)]
+		case "a":
+			m.toggleApparent()
+		case "%":
+			m.hidePercent = !m.hidePercent
+		case "b":
+			m.hideBar = !m.hideBar
+		case "d", "delete":
+			m.ask("delete")
+		case "t":
+			m.ask("trash")
+		case "m":
+			if m.ask("move") {
+				m.input.SetValue("")
+				return m, m.input.Focus()
+			}
+		}
+
+	case actionMsg:
+		m.finishAction(msg)
+
+	case partialMsg:
+		selected, _ := m.selected()
+		attach([]*file{m.root}, msg.f)
+		m.buildRows()
+		// the cursor stays on the largest entry until it is moved.
+		if m.cursor > 0 {
+			m.selectFile(selected.f)
+		}
+
+	case scanDoneMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, tea.Quit
+		}
+
+		selected, _ := m.selected()
+		m.scanning = false
+		m.root, m.dirs[0] = msg.root, msg.root
+		m.buildRows()
+		m.selectFile(selected.f)
+
+	case spinner.TickMsg:
+		if !m.scanning {
+			return m, nil
+		}
+
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
+	case tea.MouseMsg:
+		switch msg.Button {
+		case tea.MouseButtonWheelUp:
+			m.move(-3)
+		case tea.MouseButtonWheelDown:
+			m.move(3)
 		}
 
 	case tea.WindowSizeMsg:
-		headerHeight := lipgloss.Height(m.headerView())
-		footerHeight := lipgloss.Height(m.footerView())
-		verticalMarginHeight := headerHeight + footerHeight
-
-		if !m.ready {
-			// Since this program is using the full size of the viewport we
-			// need to wait until we've received the window dimensions before
-			// we can initialize the viewport. The initial dimensions come in
-			// quickly, though asynchronously, which is why we wait for them
-			// here.
-			m.viewport = viewport.New(msg.Width, msg.Height-verticalMarginHeight)
-			m.viewport.YPosition = headerHeight
-			m.viewport.SetContent(m.content)
-			m.ready = true
-
-			// This is only necessary for high performance rendering, which in
-			// most cases you won't need.
-			//
-			// Render the viewport one line below the header.
-			m.viewport.YPosition = headerHeight + 1
-		} else {
-			m.viewport.Width = msg.Width
-			m.viewport.Height = msg.Height - verticalMarginHeight
+		m.width, m.height = msg.Width, msg.Height
+		m.ready = true
+		m.move(0)
+	}
+
+	return m, nil
+}
+
+// buildRows lists the entries of the current directory and of the expanded
+// directories below it.
+func (m *model) buildRows() {
+	m.rows = m.rows[:0]
+	if m.match != nil {
+		m.matches = make(map[*file]matched)
+		m.matches[m.current()], _ = m.sumMatches(m.current())
+	}
+	m.appendRows(m.current().sub, 0, -1)
+}
+
+func (m *model) appendRows(files []*file, depth, parent int) {
+	for _, f := range m.sorted(files) {
+		if !m.visible(f) {
+			continue
+		}
+
+		m.rows = append(m.rows, row{f: f, depth: depth, parent: parent})
+		if f.isDir && m.expanded[f] {
+			m.appendRows(f.sub, depth+1, len(m.rows)-1)
+		}
+	}
+}
+
+// sorted returns files in the selected sort order.
+func (m *model) sorted(files []*file) []*file {
+	files = slices.Clone(files)
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		switch sortOrders[m.sortOrder] {
+		case "name":
+			return a.name < b.name
+		case "count":
+			return m.count(a) > m.count(b)
+		case "mtime":
+			return a.mtime.After(b.mtime)
+		default:
+			return m.size(a) > m.size(b)
 		}
+	})
+
+	return files
+}
+
+// rebuild lists the rows again, keeping the cursor on the selected file.
+func (m *model) rebuild() {
+	selected, _ := m.selected()
+	m.buildRows()
+	m.selectFile(selected.f)
+}
+
+// toggleApparent switches between the allocated and the apparent sizes.
+// The sizes are picked again from the tree, not scanned again.
+func (m *model) toggleApparent() {
+	if m.scanning {
+		m.status = "wait for the scan to finish to switch sizes"
+		return
+	}
+
+	apparentSize = !apparentSize
+	m.root.setSizes()
+	m.rebuild()
+}
 
+// visible applies -a and -D like markPrint does, and the filter.
+func (m *model) visible(f *file) bool {
+	if !f.isDir && m.directory {
+		return false
+	}
+	if m.match != nil {
+		_, ok := m.matches[f]
+		return ok
 	}
 
-	// Handle keyboard and mouse events in the viewport
-	m.viewport, cmd = m.viewport.Update(msg)
-	cmds = append(cmds, cmd)
+	return !f.isDir || f.fileCount() != 0 || m.all || f.errorMark() != ""
+}
+
+func (m *model) current() *file {
+	return m.dirs[len(m.dirs)-1]
+}
+
+func (m *model) selected() (row, bool) {
+	if m.cursor >= len(m.rows) {
+		return row{}, false
+	}
 
-	return m, tea.Batch(cmds...)
+	return m.rows[m.cursor], true
+}
+
+// move moves the cursor by n rows and scrolls to keep it in sight.
+func (m *model) move(n int) {
+	m.cursor = min(max(m.cursor+n, 0), max(len(m.rows)-1, 0))
+
+	height := m.listHeight()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+height {
+		m.offset = m.cursor - height + 1
+	}
+	m.offset = min(m.offset, max(len(m.rows)-height, 0))
+}
+
+// expand shows the entries of the selected directory in place, or moves to
+// the first of them when it is already expanded.
+func (m *model) expand() {
+	r, ok := m.selected()
+	if !ok || !r.f.isDir {
+		return
+	}
+
+	if m.expanded[r.f] {
+		if m.cursor+1 < len(m.rows) && m.rows[m.cursor+1].parent == m.cursor {
+			m.move(1)
+		}
+		return
+	}
+
+	m.expanded[r.f] = true
+	m.buildRows()
+	m.move(0)
+}
+
+// collapse hides the entries of the selected directory, or moves to the
+// directory holding it, or goes up from the current directory.
+func (m *model) collapse() {
+	r, ok := m.selected()
+	switch {
+	case ok && r.f.isDir && m.expanded[r.f]:
+		delete(m.expanded, r.f)
+		m.buildRows()
+		m.move(0)
+	case ok && r.parent >= 0:
+		m.move(r.parent - m.cursor)
+	default:
+		m.up()
+	}
+}
+
+// open makes the selected directory the current one.
+func (m *model) open() {
+	r, ok := m.selected()
+	if !ok || !r.f.isDir {
+		return
+	}
+
+	m.dirs = append(m.dirs, r.f)
+	m.buildRows()
+	m.cursor, m.offset = 0, 0
+}
+
+// up goes back to the directory holding the current one, with the cursor on
+// the directory that was left.
+func (m *model) up() {
+	if len(m.dirs) == 1 {
+		return
+	}
+
+	left := m.current()
+	m.dirs = m.dirs[:len(m.dirs)-1]
+	m.buildRows()
+	m.offset = 0
+	m.selectFile(left)
+}
+
+// selectFile moves the cursor to the row of f, or to the first row when f
+// is not listed.
+func (m *model) selectFile(f *file) {
+	m.cursor = 0
+	for i, r := range m.rows {
+		if r.f == f {
+			m.cursor = i
+			break
+		}
+	}
+	m.move(0)
+}
+
+// size returns the size of f, or of the part of its tree matching the
+// filter.
+func (m *model) size(f *file) int64 {
+	if m.match != nil {
+		return m.matches[f].size
+	}
+
+	return f.size
+}
+
+// count returns the number of files in the tree of f, or of those matching
+// the filter.
+func (m *model) count(f *file) int64 {
+	if m.match != nil {
+		return m.matches[f].count
+	}
+
+	return f.fileCount()
+}
+
+// breadcrumb returns the path of the current directory.
+func (m *model) breadcrumb() string {
+	names := make([]string, 0, len(m.dirs))
+	for _, dir := range m.dirs {
+		names = append(names, dir.name)
+	}
+
+	return filepath.Join(names...)
+}
+
+func (m *model) listHeight() int {
+	return max(m.height-lipgloss.Height(m.headerView())-lipgloss.Height(m.footerView()), 1)
 }
 
 func (m model) View() string {
 	if !m.ready {
 		return "\n  Initializing..."
 	}
-	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.viewport.View(), m.footerView())
+
+	if m.action != "" {
+		dialog := lipgloss.Place(m.width, m.listHeight(), lipgloss.Center, lipgloss.Center, m.dialogView())
+		return fmt.Sprintf("%s\n%s\n%s", m.headerView(), dialog, m.footerView())
+	}
+
+	lines := make([]string, 0, m.listHeight())
+	for i := m.offset; i < len(m.rows) && len(lines) < m.listHeight(); i++ {
+		lines = append(lines, m.rowView(i))
+	}
+	for len(lines) < m.listHeight() {
+		lines = append(lines, "")
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), strings.Join(lines, "\n"), m.footerView())
 }
 
-func (m model) headerView() string {
-	return lipgloss.JoinHorizontal(lipgloss.Center)
+func (m *model) rowView(i int) string {
+	r := m.rows[i]
+	size, total := m.size(r.f), m.size(m.current())
+	rate := usageRate(size, total)
+
+	filled := 0
+	if total > 0 {
+		filled = int(float64(size) / float64(total) * barWidth)
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat(" ", barWidth-filled)
+
+	marker := "  "
+	if r.f.isDir {
+		marker = "▸ "
+		if m.expanded[r.f] {
+			marker = "▾ "
+		}
+	}
+
+	name := m.highlight(r.f.name, lipgloss.NewStyle())
+	if r.f.isDir {
+		name = m.highlight(r.f.name, dirStyle) + dirStyle.Render(string(filepath.Separator))
+	}
+	if mark := r.f.errorMark(); mark != "" {
+		name = markStyle.Render(mark) + " " + name
+	}
+
+	columns := []string{fmt.Sprintf("%8s", formatSize(m.unit, size))}
+	if !m.hidePercent {
+		columns = append(columns, fmt.Sprintf("%5.1f%%", rate))
+	}
+	if !m.hideBar {
+		columns = append(columns, "["+bar+"]")
+	}
+	switch sortOrders[m.sortOrder] {
+	case "count":
+		columns = append(columns, fmt.Sprintf("%8d", m.count(r.f)))
+	case "mtime":
+		mtime := strings.Repeat(" ", len(time.DateTime))
+		if !r.f.mtime.IsZero() {
+			mtime = r.f.mtime.Format(time.DateTime)
+		}
+		columns = append(columns, mtime)
+	}
+	columns = append(columns, strings.Repeat("  ", r.depth)+marker+name)
+
+	line := strings.Join(columns, " ")
+	line = lipgloss.NewStyle().MaxWidth(m.width).Render(line)
+	if i == m.cursor {
+		line = cursorStyle.Render(line)
+	}
+
+	return line
 }
 
-func (m model) footerView() string {
-	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)))
-	return lipgloss.JoinHorizontal(lipgloss.Center, line, info)
+func (m *model) headerView() string {
+	current := m.current()
+	size := formatSize(m.unit, current.size)
+	if m.match != nil {
+		size = formatSize(m.unit, m.size(current)) + " matching of " + size
+	}
+	title := titleStyle.Render(fmt.Sprintf("%s  %s", m.breadcrumb(), size))
+
+	mode := "disk"
+	if apparentSize {
+		mode = "apparent"
+	}
+	unit := m.unit
+	if unit == "A" {
+		unit = "auto"
+	}
+	info := infoStyle.Render(fmt.Sprintf("sort %s · unit %s · %s", sortOrders[m.sortOrder], unit, mode))
+
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(title)-lipgloss.Width(info)))
+	return lipgloss.JoinHorizontal(lipgloss.Center, title, line, info)
+}
+
+func (m *model) footerView() string {
+	help := helpStyle.Render(" ↑/↓ move  →/l expand  enter open  ←/h collapse  backspace up  / filter  s sort  u unit  a apparent  %/b columns  d delete  t trash  m move  q quit ")
+	if m.match != nil {
+		help = fmt.Sprintf(" %s%s  %s ", m.search.Prompt, m.search.Value(), helpStyle.Render("esc clears"))
+	}
+	if m.status != "" {
+		help = " " + m.status + " "
+	}
+	if m.scanning {
+		help = fmt.Sprintf(" %s %d files, %d dirs, %s  %s ", m.spinner.View(),
+			scanProgress.files.Load(), scanProgress.dirs.Load(),
+			formatSize(m.unit, scanProgress.bytes.Load()), currentDir())
+	}
+	if m.search.Focused() {
+		help = " " + m.search.View() + "  " + helpStyle.Render("tab mode  enter keep  esc clear") + " "
+		if m.searchErr != nil {
+			help += markStyle.Render(m.searchErr.Error()) + " "
+		}
+	}
+	position := 100.0
+	if len(m.rows) > 1 {
+		position = float64(m.cursor) / float64(len(m.rows)-1) * 100
+	}
+	info := infoStyle.Render(fmt.Sprintf("%d/%d %3.f%%", min(m.cursor+1, len(m.rows)), len(m.rows), position))
+	help = lipgloss.NewStyle().MaxWidth(max(m.width-lipgloss.Width(info), 0)).Render(help)
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(info)-lipgloss.Width(help)))
+	return lipgloss.JoinHorizontal(lipgloss.Center, help, line, info)
+}
+
+// ask asks for a confirmation of an action on the selected file. Files
+// whose tree was not scanned completely, left out by the filters or not
+// read, are refused since the action would reach files the preview does not
+// count.
+func (m *model) ask(action string) bool {
+	r, ok := m.selected()
+	if !ok || m.busy {
+		return false
+	}
+	switch {
+	case m.scanning:
+		m.status = "wait for the scan to finish to " + action
+		return false
+	case m.rescan == nil:
+		m.status = "can not " + action + " the files of an imported tree"
+		return false
+	case r.f.skipped:
+		m.status = "can not " + action + " " + r.f.name + ", the scan left some of its files out"
+		return false
+	case r.f.errorMark() != "":
+		m.status = "can not " + action + " " + r.f.name + ", some of its files could not be read"
+		return false
+	}
+
+	m.action = action
+	return true
+}
+
+func (m model) updateAction(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.input.Focused() {
+		switch msg.String() {
+		case "esc", "ctrl+c":
+			m.input.Blur()
+			m.action = ""
+		case "enter":
+			if m.input.Value() != "" {
+				m.input.Blur()
+			}
+		default:
+			var cmd tea.Cmd
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "y", "Y":
+		action := m.action
+		m.action, m.busy = "", true
+		m.status = action + " in progress..."
+		return m, m.runAction(action)
+	case "n", "N", "esc", "q", "ctrl+c":
+		m.action = ""
+	}
+
+	return m, nil
+}
+
+// runAction returns a command running the action on the selected file.
+func (m *model) runAction(action string) tea.Cmd {
+	r, _ := m.selected()
+	ancestors := m.ancestors(m.cursor)
+	path := m.path(m.cursor)
+	dir := m.destination()
+	rescan := m.rescan
+
+	return func() tea.Msg {
+		msg := actionMsg{action: action, f: r.f, ancestors: ancestors}
+		switch action {
+		case "delete":
+			msg.err = removeFile(path)
+		case "trash":
+			msg.err = trashFile(path)
+		case "move":
+			msg.target, msg.err = moveFile(path, dir)
+		}
+		if msg.err != nil {
+			msg.rescanned = rescanFile(path, r.f, rescan)
+		}
+
+		return msg
+	}
+}
+
+// finishAction updates the sizes of the tree after an action, without
+// scanning again unless the action failed part way.
+func (m *model) finishAction(msg actionMsg) {
+	m.busy = false
+	detach(msg.ancestors, msg.f)
+	delete(m.expanded, msg.f)
+	done := map[string]string{"delete": "deleted", "trash": "trashed", "move": "moved"}[msg.action]
+	m.status = fmt.Sprintf("%s %s, freed %s", done, msg.f.name, m.freed(msg.f))
+
+	if msg.err != nil {
+		m.status = fmt.Sprintf("%s failed: %v", msg.action, msg.err)
+		if msg.rescanned != nil {
+			attach(msg.ancestors, msg.rescanned)
+		}
+	}
+
+	if msg.target != "" {
+		if ancestors, ok := m.lookupDir(filepath.Dir(msg.target)); ok {
+			attach(ancestors, msg.f)
+			if msg.err == nil {
+				m.status = "moved " + msg.f.name + " within the tree"
+			}
+		}
+	}
+
+	m.buildRows()
+	m.move(0)
+}
+
+// lookupDir returns the directory at path along with its ancestors, when it
+// is part of the scanned tree.
+func (m *model) lookupDir(path string) ([]*file, bool) {
+	root, err := filepath.Abs(m.root.name)
+	if err != nil {
+		return nil, false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, false
+	}
+
+	return lookup(m.root, rel)
+}
+
+// destination returns the directory typed for a move.
+func (m *model) destination() string {
+	dir := m.input.Value()
+	if home, err := os.UserHomeDir(); err == nil && (dir == "~" || strings.HasPrefix(dir, "~/")) {
+		dir = filepath.Join(home, dir[1:])
+	}
+
+	return dir
+}
+
+// ancestors returns the directories from the root to the one holding the
+// file of row i.
+func (m *model) ancestors(i int) []*file {
+	var expanded []*file
+	for p := m.rows[i].parent; p >= 0; p = m.rows[p].parent {
+		expanded = append(expanded, m.rows[p].f)
+	}
+
+	ancestors := append([]*file{}, m.dirs...)
+	for j := len(expanded) - 1; j >= 0; j-- {
+		ancestors = append(ancestors, expanded[j])
+	}
+
+	return ancestors
+}
+
+// path returns the path of the file of row i.
+func (m *model) path(i int) string {
+	var names []string
+	for _, a := range m.ancestors(i) {
+		names = append(names, a.name)
+	}
+
+	return filepath.Join(append(names, m.rows[i].f.name)...)
+}
+
+// dialogView previews the action waiting for a confirmation.
+func (m *model) dialogView() string {
+	r, _ := m.selected()
+	path := m.path(m.cursor)
+	freed := m.freed(r.f) + " in 1 file"
+	if n := r.f.fileCount(); n != 1 {
+		freed = fmt.Sprintf("%s in %d files", m.freed(r.f), n)
+	}
+
+	var lines []string
+	switch m.action {
+	case "delete":
+		lines = []string{"Delete " + path + "?", "This frees " + freed + "."}
+	case "trash":
+		lines = []string{"Move " + path + " to the trash?", "This frees " + freed + " from the tree, kept in the trash."}
+	case "move":
+		if m.input.Focused() {
+			return dialogStyle.Render(lipgloss.JoinVertical(lipgloss.Left,
+				"Move "+path, "", m.input.View(), "", helpStyle.Render("enter confirm  esc cancel")))
+		}
+
+		lines = []string{"Move " + path + " to " + m.destination() + "?"}
+		if _, ok := m.lookupDir(m.destination()); ok {
+			lines = append(lines, "This moves "+freed+" within the tree.")
+		} else {
+			lines = append(lines, "This frees "+freed+" from the tree.")
+		}
+	}
+
+	if r.f.links > 0 && m.action != "move" {
+		note := "It has other hard links, which keep its data on disk."
+		if r.f.isDir {
+			note = fmt.Sprintf("%d of its files have other hard links, which keep their data on disk.", r.f.links)
+		}
+		lines = append(lines, note)
+	}
+
+	lines = append(lines, "", helpStyle.Render("y confirm  n cancel"))
+
+	return dialogStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// freed returns the size released by removing f. The data of a file with
+// other hard links is only released with its last link, so that size is a
+// maximum.
+func (m *model) freed(f *file) string {
+	if f.links > 0 {
+		return "up to " + formatSize(m.unit, f.size)
+	}
+
+	return formatSize(m.unit, f.size)
 }
 
 func max(a, b int) int {
@@ -104,20 +854,38 @@ func max(a, b int) int {
 	return b
 }
 
-func rendering(interactive bool, s string) {
-	if !interactive {
-		fmt.Print(s)
-		return
-	}
+// browse opens the interactive tree of dir right away, while load scans it.
+// The top level subtrees can be browsed as soon as their scan is done.
+// rescan scans a directory again after a failed action, and is nil for an
+// imported tree.
+func browse(dir string, load func() (*file, error), rescan func(dir string) (*file, error), unit string, all, directory bool) error {
+	m := newModel(&file{name: dir, isDir: true}, unit, all, directory)
+	m.scanning, m.rescan = true, rescan
+	m.spinner = spinner.New(spinner.WithSpinner(spinner.Dot))
 
 	p := tea.NewProgram(
-		model{content: s},
+		m,
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
 
-	if _, err := p.Run(); err != nil {
+	visit = func(_ string, depth int, f *file) {
+		if depth == 1 {
+			p.Send(partialMsg{f: f})
+		}
+	}
+	go func() {
+		root, err := load()
+		// the directories scanned again are not part of the top level.
+		visit = nil
+		p.Send(scanDoneMsg{root: root, err: err})
+	}()
+
+	final, err := p.Run()
+	if err != nil {
 		fmt.Println("could not run program:", err)
 		os.Exit(1)
 	}
+
+	return final.(model).err
 }
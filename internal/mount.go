@@ -0,0 +1,94 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	oneFileSystem bool
+	rootDevice    uint64
+	mountsMu      sync.Mutex
+	skippedMounts []string
+)
+
+func setOneFileSystem(flags *flag.FlagSet, dir string) error {
+	one, err := flags.GetBool("one-file-system")
+	if err != nil {
+		return err
+	}
+
+	if !one {
+		return nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+
+	rootDevice, oneFileSystem = device(info)
+
+	return nil
+}
+
+// otherFileSystem reports whether the directory lies on another file system
+// than the scanned directory, in which case it is recorded as a skipped
+// mount point.
+func otherFileSystem(info os.FileInfo, dir string) bool {
+	if !oneFileSystem {
+		return false
+	}
+
+	dev, ok := device(info)
+	if !ok || dev == rootDevice {
+		return false
+	}
+
+	skipMount(dir, "other file system")
+
+	return true
+}
+
+// skipMount records a directory that was not descended into because of the
+// file system it is on.
+func skipMount(dir, reason string) {
+	mountsMu.Lock()
+	skippedMounts = append(skippedMounts, fmt.Sprintf("%s (%s)", dir, reason))
+	mountsMu.Unlock()
+}
+
+// printSkippedMounts lists the mount points that were not descended into on
+// stderr, keeping stdout for the report itself.
+func printSkippedMounts() {
+	mountsMu.Lock()
+	defer mountsMu.Unlock()
+
+	if len(skippedMounts) == 0 {
+		return
+	}
+
+	sort.Strings(skippedMounts)
+	_, _ = fmt.Fprintf(os.Stderr, "Skipped %d mount points:\n", len(skippedMounts))
+	for _, mount := range skippedMounts {
+		_, _ = fmt.Fprintln(os.Stderr, "  "+mount)
+	}
+}
@@ -0,0 +1,221 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// The ncdu JSON dump format is documented at https://dev.yorhel.nl/ncdu/jsonfmt.
+// A dump is [major, minor, metadata, root], where a directory is an array whose
+// first element describes the directory itself and the remaining elements are
+// its entries: objects for files and arrays for sub directories.
+const (
+	ncduMajorVersion = 1
+	ncduMinorVersion = 2
+)
+
+type (
+	ncduMeta struct {
+		Progname  string `json:"progname"`
+		Progver   string `json:"progver"`
+		Timestamp int64  `json:"timestamp"`
+	}
+
+	// ncduEntry describes a file or directory. dev is only written when it
+	// differs from the one of the parent directory, and the sizes of a hard
+	// linked file, marked by hlnkc, are the whole ones on every link.
+	ncduEntry struct {
+		Name      string `json:"name"`
+		Asize     int64  `json:"asize,omitempty"`
+		Dsize     int64  `json:"dsize,omitempty"`
+		Dev       uint64 `json:"dev,omitempty"`
+		Ino       uint64 `json:"ino,omitempty"`
+		Nlink     uint64 `json:"nlink,omitempty"`
+		Hlnkc     bool   `json:"hlnkc,omitempty"`
+		ReadError bool   `json:"read_error,omitempty"`
+		Excluded  string `json:"excluded,omitempty"`
+	}
+)
+
+// exportNCDU writes the whole tree in the format read by `ncdu -f`.
+func exportNCDU(cmd *cobra.Command, root *file) error {
+	progver := ""
+	if fields := strings.Fields(cmd.Root().Version); len(fields) > 0 {
+		progver = fields[0]
+	}
+
+	meta, err := json.Marshal(ncduMeta{
+		Progname:  cmd.Root().Name(),
+		Progver:   progver,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "[%d,%d,%s,", ncduMajorVersion, ncduMinorVersion, meta)
+	if err := writeNCDUDir(root); err != nil {
+		return err
+	}
+	out.WriteString("]\n")
+
+	return nil
+}
+
+func writeNCDUDir(dir *file) error {
+	// ncdu keeps the size of a directory itself apart from its entries.
+	own := ncduEntry{Name: dir.name, Asize: dir.apparent, Dsize: dir.disk, ReadError: dir.readError}
+	for _, f := range dir.sub {
+		own.Asize -= f.apparent
+		own.Dsize -= f.disk
+	}
+
+	entry, err := json.Marshal(own)
+	if err != nil {
+		return err
+	}
+
+	out.WriteString("[")
+	out.Write(entry)
+	for _, f := range dir.sub {
+		out.WriteString(",")
+		if f.isDir {
+			if err := writeNCDUDir(f); err != nil {
+				return err
+			}
+			continue
+		}
+
+		entry := ncduEntry{Name: f.name, Asize: f.apparent, Dsize: f.disk}
+		if f.link != nil {
+			entry.Asize, entry.Dsize = f.link.apparent, f.link.disk
+			entry.Ino, entry.Nlink, entry.Hlnkc = f.link.key.ino, f.link.nlink, true
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		out.Write(data)
+	}
+	out.WriteString("]")
+
+	return nil
+}
+
+// importNCDU reads a dump written by `ncdu -o` and returns its root directory.
+func importNCDU(name string) (*file, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dump []json.RawMessage
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, errors.New("invalid ncdu dump: " + err.Error())
+	}
+	if len(dump) < 4 {
+		return nil, errors.New("invalid ncdu dump: missing root directory")
+	}
+
+	var major int
+	if err := json.Unmarshal(dump[0], &major); err != nil || major != ncduMajorVersion {
+		return nil, errors.New("unsupported ncdu dump version")
+	}
+
+	return parseNCDUDir(dump[3], 0)
+}
+
+// parseNCDUDir reads a directory of a dump held by a directory on device dev.
+// The hard linked files are counted like a scan does, once per inode.
+func parseNCDUDir(data json.RawMessage, dev uint64) (*file, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, errors.New("invalid ncdu dump: " + err.Error())
+	}
+	if len(items) == 0 {
+		return nil, errors.New("invalid ncdu dump: empty directory")
+	}
+
+	var entry ncduEntry
+	if err := json.Unmarshal(items[0], &entry); err != nil {
+		return nil, errors.New("invalid ncdu dump: " + err.Error())
+	}
+
+	if entry.Dev != 0 {
+		dev = entry.Dev
+	}
+
+	dir := &file{name: entry.Name, isDir: true}
+	for _, item := range items[1:] {
+		var f *file
+		if bytes.HasPrefix(bytes.TrimSpace(item), []byte("[")) {
+			sub, err := parseNCDUDir(item, dev)
+			if err != nil {
+				return nil, err
+			}
+			f = sub
+		} else {
+			var entry ncduEntry
+			if err := json.Unmarshal(item, &entry); err != nil {
+				return nil, errors.New("invalid ncdu dump: " + err.Error())
+			}
+			if entry.Excluded != "" {
+				continue
+			}
+			f = &file{name: entry.Name, disk: entry.Dsize, apparent: entry.Asize}
+			if entry.Hlnkc && entry.Ino != 0 {
+				key := fileKey{dev: dev, ino: entry.Ino}
+				if entry.Dev != 0 {
+					key.dev = entry.Dev
+				}
+				f.link = &fileLink{key: key, nlink: entry.Nlink, disk: entry.Dsize, apparent: entry.Asize}
+				f.disk, f.apparent = f.link.counted()
+				f.links = 1
+			}
+			f.setSize()
+		}
+
+		dir.sub = append(dir.sub, f)
+	}
+	dir.readError = entry.ReadError
+	dir.sumSub()
+	if countDirBlocks {
+		dir.disk += entry.Dsize
+		dir.apparent += entry.Asize
+		dir.setSize()
+	}
+	sort.Slice(dir.sub, func(i, j int) bool { return dir.sub[i].size > dir.sub[j].size })
+
+	return dir, nil
+}
@@ -0,0 +1,79 @@
+package internal
+
+import "testing"
+
+func TestNCDURoundTrip(t *testing.T) {
+	newFile := func(name string, disk, apparent int64) *file {
+		f := &file{name: name, disk: disk, apparent: apparent}
+		f.setSize()
+		return f
+	}
+	// the two links of a file, counted under the first one like a scan does.
+	newLink := func(name string, counted bool) *file {
+		f := newFile(name, 0, 0)
+		f.link = &fileLink{key: fileKey{ino: 42}, nlink: 2, disk: 8192, apparent: 5000}
+		f.links = 1
+		if counted {
+			f.disk, f.apparent = f.link.disk, f.link.apparent
+			f.setSize()
+		}
+		return f
+	}
+	newDir := func(name string, ownDisk, ownApparent int64, readError bool, sub ...*file) *file {
+		dir := &file{name: name, isDir: true, sub: sub, readError: readError}
+		dir.sumSub()
+		dir.disk += ownDisk
+		dir.apparent += ownApparent
+		dir.setSize()
+		return dir
+	}
+
+	root := newDir("/data", 4096, 4096, false,
+		newDir("logs", 8192, 6000, true,
+			newFile("app.log", 1<<20, 1<<20-100),
+			newDir("old", 4096, 4096, false, newFile("a.log.gz", 8192, 5000)),
+			newLink("app.bin", true),
+		),
+		newFile("sparse.img", 12288, 1<<30),
+		newDir("backup", 8192, 8192, false, newLink("app.bin", false)),
+		newDir("empty", 4096, 4096, false),
+	)
+
+	hardLinks = map[fileKey]struct{}{}
+	defer func() { hardLinks = map[fileKey]struct{}{} }()
+
+	out.Reset()
+	defer out.Reset()
+	if err := writeNCDUDir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := parseNCDUDir(out.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var compare func(path string, want, got *file)
+	compare = func(path string, want, got *file) {
+		if got.name != want.name || got.isDir != want.isDir || got.readError != want.readError {
+			t.Errorf("%s: got %q dir %v error %v, want %q dir %v error %v",
+				path, got.name, got.isDir, got.readError, want.name, want.isDir, want.readError)
+		}
+		if got.size != want.size || got.disk != want.disk || got.apparent != want.apparent {
+			t.Errorf("%s: got size %d disk %d apparent %d, want size %d disk %d apparent %d",
+				path, got.size, got.disk, got.apparent, want.size, want.disk, want.apparent)
+		}
+		if got.links != want.links || (got.link == nil) != (want.link == nil) ||
+			want.link != nil && *got.link != *want.link {
+			t.Errorf("%s: got links %d %+v, want links %d %+v", path, got.links, got.link, want.links, want.link)
+		}
+		if len(got.sub) != len(want.sub) {
+			t.Errorf("%s: got %d entries, want %d", path, len(got.sub), len(want.sub))
+			return
+		}
+		for i := range want.sub {
+			compare(path+"/"+want.sub[i].name, want.sub[i], got.sub[i])
+		}
+	}
+	compare(root.name, root, got)
+}
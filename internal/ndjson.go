@@ -0,0 +1,80 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type ndjsonRecord struct {
+	Path      string `json:"path"`
+	Parent    string `json:"parent"`
+	Depth     int    `json:"depth"`
+	Size      int64  `json:"size"`
+	IsDir     bool   `json:"isDir"`
+	ReadError bool   `json:"readError,omitempty"`
+	SubError  bool   `json:"subError,omitempty"`
+}
+
+var (
+	ndjsonMu     sync.Mutex
+	ndjsonWriter *bufio.Writer
+	ndjsonErr    error
+)
+
+// startNDJSON makes find write one JSON record per file or directory to
+// stdout as soon as its size is known. Directories are written after their
+// children, which are released right away, and the scanned directory comes
+// last with the total.
+func startNDJSON() {
+	ndjsonWriter = bufio.NewWriter(os.Stdout)
+	encoder := json.NewEncoder(ndjsonWriter)
+
+	stream = true
+	visit = func(path string, depth int, f *file) {
+		ndjsonMu.Lock()
+		defer ndjsonMu.Unlock()
+
+		if ndjsonErr != nil {
+			return
+		}
+
+		ndjsonErr = encoder.Encode(ndjsonRecord{
+			Path:      path,
+			Parent:    filepath.Dir(path),
+			Depth:     depth,
+			Size:      f.size,
+			IsDir:     f.isDir,
+			ReadError: f.readError,
+			SubError:  f.subError,
+		})
+	}
+}
+
+// stopNDJSON flushes the records that are still buffered.
+func stopNDJSON() error {
+	ndjsonMu.Lock()
+	defer ndjsonMu.Unlock()
+
+	if ndjsonErr != nil {
+		return ndjsonErr
+	}
+
+	return ndjsonWriter.Flush()
+}
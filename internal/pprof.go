@@ -0,0 +1,219 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"compress/gzip"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Field numbers of the messages in
+// https://github.com/google/pprof/blob/main/proto/profile.proto.
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+)
+
+// pprofBuilder encodes the tree as a pprof profile. Every directory and file
+// is a frame named after its base name, with its path as the file name, so
+// the stack of a sample is the path of a file read from the leaf to the root,
+// and its values are the bytes used by the file and a file count of one. The
+// own size of every directory is a sample of its own with a file count of
+// zero.
+type pprofBuilder struct {
+	profile protoBuffer
+	strings map[string]int64
+	table   []string
+	nextID  uint64
+}
+
+// printPprof writes the whole tree of root as a gzipped pprof protobuf
+// profile.
+func printPprof(root *file) error {
+	b := &pprofBuilder{strings: map[string]int64{}}
+	b.string("")
+
+	space, bytesUnit := b.string("space"), b.string("bytes")
+	b.profile.message(profileSampleType, b.valueType(space, bytesUnit))
+	b.profile.message(profileSampleType, b.valueType(b.string("files"), b.string("count")))
+
+	name := filepath.Base(root.name)
+	b.addFile(name, name, nil, root)
+
+	b.profile.int64(profileTimeNanos, time.Now().UnixNano())
+	b.profile.message(profilePeriodType, b.valueType(space, bytesUnit))
+	b.profile.int64(profilePeriod, 1)
+	b.profile.int64(profileDefaultSampleType, space)
+	for _, s := range b.table {
+		b.profile.string(profileStringTable, s)
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := gz.Write(b.profile.data); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func (b *pprofBuilder) addFile(name, filePath string, stack []uint64, f *file) {
+	stack = append([]uint64{b.frame(name, filePath)}, stack...)
+
+	size, count := f.size, int64(1)
+	if f.isDir {
+		size, count = f.ownSize(), 0
+	}
+	if !f.isDir || size > 0 {
+		var sample protoBuffer
+		sample.packedUint64(sampleLocationID, stack)
+		sample.packedInt64(sampleValue, []int64{size, count})
+		b.profile.message(profileSample, sample)
+	}
+
+	for _, sub := range f.sub {
+		b.addFile(sub.name, path.Join(filePath, sub.name), stack, sub)
+	}
+}
+
+// frame adds a function and a location for the file name at filePath and
+// returns their id.
+func (b *pprofBuilder) frame(name, filePath string) uint64 {
+	b.nextID++
+	id := b.nextID
+	nameIndex := b.string(name)
+
+	var function protoBuffer
+	function.uint64(functionID, id)
+	function.int64(functionName, nameIndex)
+	function.int64(functionSystemName, nameIndex)
+	function.int64(functionFilename, b.string(filePath))
+	b.profile.message(profileFunction, function)
+
+	var line protoBuffer
+	line.uint64(lineFunctionID, id)
+
+	var location protoBuffer
+	location.uint64(locationID, id)
+	location.message(locationLine, line)
+	b.profile.message(profileLocation, location)
+
+	return id
+}
+
+func (b *pprofBuilder) valueType(typ, unit int64) protoBuffer {
+	var valueType protoBuffer
+	valueType.int64(valueTypeType, typ)
+	valueType.int64(valueTypeUnit, unit)
+
+	return valueType
+}
+
+// string returns the index of s in the string table.
+func (b *pprofBuilder) string(s string) int64 {
+	if index, ok := b.strings[s]; ok {
+		return index
+	}
+
+	index := int64(len(b.table))
+	b.strings[s] = index
+	b.table = append(b.table, s)
+
+	return index
+}
+
+// protoBuffer is a minimal protocol buffers encoder covering the field types
+// used by the pprof profile format.
+type protoBuffer struct {
+	data []byte
+}
+
+func (p *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		p.data = append(p.data, byte(x)|0x80)
+		x >>= 7
+	}
+	p.data = append(p.data, byte(x))
+}
+
+func (p *protoBuffer) key(tag int, wireType uint64) {
+	p.varint(uint64(tag)<<3 | wireType)
+}
+
+func (p *protoBuffer) uint64(tag int, x uint64) {
+	if x == 0 {
+		return
+	}
+	p.key(tag, 0)
+	p.varint(x)
+}
+
+func (p *protoBuffer) int64(tag int, x int64) {
+	p.uint64(tag, uint64(x))
+}
+
+func (p *protoBuffer) bytes(tag int, data []byte) {
+	p.key(tag, 2)
+	p.varint(uint64(len(data)))
+	p.data = append(p.data, data...)
+}
+
+func (p *protoBuffer) string(tag int, s string) {
+	p.bytes(tag, []byte(s))
+}
+
+func (p *protoBuffer) message(tag int, m protoBuffer) {
+	p.bytes(tag, m.data)
+}
+
+func (p *protoBuffer) packedUint64(tag int, xs []uint64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	p.bytes(tag, packed.data)
+}
+
+func (p *protoBuffer) packedInt64(tag int, xs []int64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	p.bytes(tag, packed.data)
+}
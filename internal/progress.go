@@ -0,0 +1,44 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"sync/atomic"
+)
+
+// scanProgress counts what find has seen so far, for the interactive view
+// to show while scanning.
+var scanProgress struct {
+	files   atomic.Int64
+	dirs    atomic.Int64
+	bytes   atomic.Int64
+	current atomic.Value
+}
+
+func progressDir(dir string) {
+	scanProgress.dirs.Add(1)
+	scanProgress.current.Store(dir)
+}
+
+func progressFile(f *file) {
+	scanProgress.files.Add(1)
+	scanProgress.bytes.Add(f.size)
+}
+
+// currentDir returns the directory find read last.
+func currentDir() string {
+	dir, _ := scanProgress.current.Load().(string)
+	return dir
+}
@@ -0,0 +1,73 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// maxPrintedErrors caps the number of scan errors listed in the summary.
+const maxPrintedErrors = 20
+
+var (
+	scanErrorsMu sync.Mutex
+	scanErrors   []error
+)
+
+// addScanError records an error met while scanning. The errors returned by
+// the os package already hold the path they are about.
+func addScanError(err error) {
+	scanErrorsMu.Lock()
+	scanErrors = append(scanErrors, err)
+	scanErrorsMu.Unlock()
+}
+
+// scanIncomplete lists the scan errors on stderr and returns an error when
+// there were any, so that the command exits non-zero.
+func scanIncomplete() error {
+	scanErrorsMu.Lock()
+	defer scanErrorsMu.Unlock()
+
+	if len(scanErrors) == 0 {
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "%d errors while scanning, the sizes are incomplete:\n", len(scanErrors))
+	for i, err := range scanErrors {
+		if i == maxPrintedErrors {
+			_, _ = fmt.Fprintf(os.Stderr, "  ... and %d more\n", len(scanErrors)-maxPrintedErrors)
+			break
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "  "+err.Error())
+	}
+
+	return fmt.Errorf("scan incomplete: %d errors", len(scanErrors))
+}
+
+// errorMark returns the mark displayed in front of an incomplete directory,
+// following ncdu: "!" when it could not be read, "." when one of its sub
+// directories could not.
+func (f *file) errorMark() string {
+	switch {
+	case f.readError:
+		return "!"
+	case f.subError:
+		return "."
+	default:
+		return ""
+	}
+}
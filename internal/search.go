@@ -0,0 +1,165 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchModes are the kinds of patterns of the interactive filter, switched
+// with tab.
+var searchModes = []string{"substring", "glob", "regex"}
+
+var matchStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true).Underline(true)
+
+type (
+	// matcher returns the start and end of the match of a name, or nil.
+	matcher func(name string) []int
+
+	// matched is the part of the tree of a file matching the filter.
+	matched struct {
+		size  int64
+		count int64
+	}
+)
+
+// newMatcher compiles a pattern of the given search mode. A substring is
+// matched ignoring case unless it holds an upper case letter.
+func newMatcher(mode, pattern string) (matcher, error) {
+	switch mode {
+	case "glob":
+		re, err := regexp.Compile("^" + globToRegexp(pattern) + "$")
+		if err != nil {
+			return nil, err
+		}
+		return re.FindStringIndex, nil
+	case "regex":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.FindStringIndex, nil
+	}
+
+	// the match is found in the name itself, so its offsets can slice it.
+	expr := regexp.QuoteMeta(pattern)
+	if strings.ToLower(pattern) == pattern {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return re.FindStringIndex, nil
+}
+
+// updateSearch edits the filter while it is typed, applying it on every key.
+func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.search.Blur()
+		m.search.SetValue("")
+	case "enter":
+		m.search.Blur()
+		return m, nil
+	case "tab":
+		m.searchMode = (m.searchMode + 1) % len(searchModes)
+	default:
+		var cmd tea.Cmd
+		m.search, cmd = m.search.Update(msg)
+		m.applySearch()
+		return m, cmd
+	}
+
+	m.applySearch()
+	return m, nil
+}
+
+// applySearch compiles the typed filter and lists the matching files again.
+// An invalid pattern keeps the previous filter.
+func (m *model) applySearch() {
+	selected, _ := m.selected()
+	m.searchErr = nil
+
+	m.search.Prompt = "/" + searchModes[m.searchMode] + ": "
+	if m.search.Value() == "" {
+		m.match = nil
+	} else if match, err := newMatcher(searchModes[m.searchMode], m.search.Value()); err != nil {
+		m.searchErr = err
+	} else {
+		m.match = match
+	}
+
+	m.buildRows()
+	m.selectFile(selected.f)
+}
+
+// sumMatches records the part of the tree of each entry of dir matching the
+// filter, and reports whether there is any. A directory whose name matches
+// counts as a whole.
+func (m *model) sumMatches(dir *file) (matched, bool) {
+	var (
+		total matched
+		found bool
+	)
+	for _, f := range dir.sub {
+		var (
+			sum matched
+			ok  bool
+		)
+		switch {
+		case m.match(f.name) != nil:
+			sum, ok = matched{size: f.size, count: f.fileCount()}, true
+			m.matchAll(f)
+		case f.isDir:
+			sum, ok = m.sumMatches(f)
+		}
+		if !ok {
+			continue
+		}
+
+		m.matches[f] = sum
+		total.size += sum.size
+		total.count += sum.count
+		found = true
+	}
+
+	return total, found
+}
+
+func (m *model) matchAll(dir *file) {
+	for _, f := range dir.sub {
+		m.matches[f] = matched{size: f.size, count: f.fileCount()}
+		m.matchAll(f)
+	}
+}
+
+// highlight marks the part of name matching the filter.
+func (m *model) highlight(name string, style lipgloss.Style) string {
+	if m.match == nil {
+		return style.Render(name)
+	}
+
+	loc := m.match(name)
+	if loc == nil || loc[0] == loc[1] {
+		return style.Render(name)
+	}
+
+	return style.Render(name[:loc[0]]) + matchStyle.Render(name[loc[0]:loc[1]]) + style.Render(name[loc[1]:])
+}
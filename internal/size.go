@@ -0,0 +1,170 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"os"
+
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	// apparentSize counts the logical size of files instead of the bytes
+	// allocated for them on disk.
+	apparentSize bool
+	// bothSizes displays the allocated and the apparent size side by side.
+	bothSizes bool
+	// countDirBlocks adds the size of every directory itself, i.e. the blocks
+	// holding its entries, to its total like du does.
+	countDirBlocks = true
+)
+
+func setSizeMode(flags *flag.FlagSet) error {
+	var err error
+	apparentSize, err = flags.GetBool("apparent-size")
+	if err != nil {
+		return err
+	}
+
+	// both-sizes is only known by the root command.
+	if flags.Lookup("both-sizes") != nil {
+		bothSizes, err = flags.GetBool("both-sizes")
+		if err != nil {
+			return err
+		}
+	}
+
+	noDirBlocks, err := flags.GetBool("no-dir-blocks")
+	if err != nil {
+		return err
+	}
+	countDirBlocks = !noDirBlocks
+
+	return nil
+}
+
+// setDuCompat makes the scan follow the semantics of GNU du:
+//   - the blocks of every directory itself are counted, including the
+//     scanned directory;
+//   - a file with several hard links is counted once, under the first path
+//     seen;
+//   - no file system is skipped, only -x stops at mount points;
+//   - the allocated size is counted unless --apparent-size is given, in
+//     which case the apparent size of directories is counted as well.
+func setDuCompat(flags *flag.FlagSet) error {
+	duCompat, err := flags.GetBool("du-compat")
+	if err != nil {
+		return err
+	}
+
+	if !duCompat {
+		return nil
+	}
+
+	if flags.Changed("no-dir-blocks") && !countDirBlocks {
+		return errors.New("du-compat counts directory blocks, it can not be used with no-dir-blocks")
+	}
+	if flags.Changed("hard-links") && hardLinkMode != hardLinkFirst {
+		return errors.New("du-compat counts hard links once, it can not be used with hard-links " + hardLinkMode)
+	}
+
+	countDirBlocks = true
+	hardLinkMode = hardLinkFirst
+	if !flags.Changed("skip-fs") {
+		skipFileSystems = map[string]bool{}
+	}
+
+	return nil
+}
+
+// addOwnSize adds the size of a directory itself to its total.
+func (f *file) addOwnSize(info os.FileInfo, path string) {
+	if !countDirBlocks {
+		return
+	}
+
+	f.disk += diskSize(info, path)
+	f.apparent += info.Size()
+	f.setSize()
+}
+
+// setSize picks the size used for sorting and display out of the allocated
+// and the apparent size of the file.
+func (f *file) setSize() {
+	if apparentSize {
+		f.size = f.apparent
+	} else {
+		f.size = f.disk
+	}
+}
+
+// setSizes picks the size of every file of the tree of f again, after the
+// size mode changed.
+func (f *file) setSizes() {
+	f.setSize()
+	for _, sub := range f.sub {
+		sub.setSizes()
+	}
+}
+
+// sumSub sets the sizes, the file count and the hard linked file count of a
+// directory to the sums of its entries, its modification time to the latest
+// of theirs, and whether any of them is incomplete or left files out.
+func (f *file) sumSub() {
+	f.disk, f.apparent, f.count, f.links, f.subError = 0, 0, 0, 0, false
+	for _, sub := range f.sub {
+		f.disk += sub.disk
+		f.apparent += sub.apparent
+		f.count += sub.fileCount()
+		f.links += sub.links
+		f.skipped = f.skipped || sub.skipped
+		if sub.mtime.After(f.mtime) {
+			f.mtime = sub.mtime
+		}
+		f.subError = f.subError || sub.readError || sub.subError
+	}
+	f.setSize()
+}
+
+// ownSize returns the size of a directory itself, its total without the
+// ones of its entries.
+func (f *file) ownSize() int64 {
+	size := f.size
+	for _, sub := range f.sub {
+		size -= sub.size
+	}
+
+	return size
+}
+
+// fileCount returns the number of files in the tree of f.
+func (f *file) fileCount() int64 {
+	if !f.isDir {
+		return 1
+	}
+
+	return f.count
+}
+
+// sparseRatio returns the allocated size in percent of the apparent size,
+// which is below 100 for sparse files.
+func sparseRatio(disk, apparent int64) float64 {
+	if apparent == 0 {
+		return 0
+	}
+
+	return float64(disk) / float64(apparent) * 100
+}
@@ -0,0 +1,162 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const snapshotVersion = 1
+
+type (
+	snapshot struct {
+		Version   int             `json:"version"`
+		Root      string          `json:"root"`
+		Timestamp time.Time       `json:"timestamp"`
+		Size      int64           `json:"size"`
+		Files     []*snapshotFile `json:"files"`
+	}
+
+	snapshotFile struct {
+		Name      string          `json:"name"`
+		IsDir     bool            `json:"isDir,omitempty"`
+		Size      int64           `json:"size"`
+		ReadError bool            `json:"readError,omitempty"`
+		Sub       []*snapshotFile `json:"sub,omitempty"`
+	}
+)
+
+// Snapshot scans a directory and saves the whole tree, so that it can be
+// compared with a later scan by Diff. Files ending with .gz are gzipped.
+func Snapshot(cmd *cobra.Command, _ []string) error {
+	flags := cmd.Flags()
+	dir, filter, err := prepareScan(flags)
+	if err != nil {
+		return err
+	}
+
+	name, err := flags.GetString("out")
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	root, err := findRoot(dir, filter)
+	if err != nil {
+		return err
+	}
+
+	s := snapshot{
+		Version:   snapshotVersion,
+		Root:      dir,
+		Timestamp: time.Now(),
+		Size:      root.size,
+		Files:     toSnapshotFiles(root.sub),
+	}
+
+	err = writeSnapshot(name, s)
+	if err != nil {
+		return err
+	}
+	printSkippedMounts()
+
+	return scanIncomplete()
+}
+
+func writeSnapshot(name string, s snapshot) error {
+	var w io.Writer = os.Stdout
+	if name != "-" {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	w = bw
+	var gz *gzip.Writer
+	if strings.HasSuffix(name, ".gz") {
+		gz = gzip.NewWriter(bw)
+		w = gz
+	}
+
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// readSnapshot reads a snapshot written by Snapshot, gzipped or not.
+func readSnapshot(name string) (snapshot, error) {
+	var s snapshot
+	f, err := os.Open(name)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return s, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return s, fmt.Errorf("invalid snapshot %s: %w", name, err)
+	}
+	if s.Version != snapshotVersion {
+		return s, fmt.Errorf("unsupported snapshot version %d in %s", s.Version, name)
+	}
+
+	return s, nil
+}
+
+func toSnapshotFiles(files []*file) []*snapshotFile {
+	snapshotFiles := make([]*snapshotFile, 0, len(files))
+	for _, f := range files {
+		snapshotFiles = append(snapshotFiles, &snapshotFile{
+			Name:      f.name,
+			IsDir:     f.isDir,
+			Size:      f.size,
+			ReadError: f.readError,
+			Sub:       toSnapshotFiles(f.sub),
+		})
+	}
+
+	return snapshotFiles
+}
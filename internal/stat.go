@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/chenquan/diskusage/internal/worker"
 	"github.com/fatih/color"
@@ -51,29 +52,65 @@ var (
 	unitStrings = []string{"B", "K", "M", "G", "T"}
 	w           *worker.Worker
 	out         = &bytes.Buffer{}
+
+	// visit is called by find for every file and directory once its size is
+	// known, and by findRoot for the scanned directory at depth 0 last. It
+	// runs concurrently from the workers.
+	visit func(path string, depth int, f *file)
+	// stream makes find drop the children of a directory after visiting it,
+	// so the whole tree is never held in memory.
+	stream bool
 )
 
 type (
 	file struct {
-		sub   []*file
-		name  string
-		isDir bool
-		size  int64
+		sub      []*file
+		name     string
+		isDir    bool
+		size     int64
+		disk     int64
+		apparent int64
+		// count is the number of files in the tree of a directory, and mtime
+		// the latest modification time in it.
+		count int64
+		mtime time.Time
 		print bool
-	}
+		// readError is set when the directory could not be read completely,
+		// subError when that is the case for one of its sub directories.
+		readError bool
+		subError  bool
+		// skipped is set when files of the tree of a directory were left out
+		// by the filters or at mount points, and links is the number of files
+		// in the tree having other hard links, link holding those of a file.
+		skipped bool
+		links   int64
+		link    *fileLink
+	}
+
+	// fileFilter decides whether find counts the file at path, which is at
+	// rel from the scanned directory and allocates disk bytes, left at 0 for
+	// directories.
+	fileFilter func(path, rel string, info fs.FileInfo, disk int64) bool
 
 	fileInfo struct {
-		size      float64
-		strLen    int
-		usageRate float64
-		uint      string
-		isDir     bool
+		path         string
+		bytes        int64
+		size         float64
+		strLen       int
+		usageRate    float64
+		uint         string
+		isDir        bool
+		disk         float64
+		diskUnit     string
+		apparent     float64
+		apparentUnit string
+		sparseRatio  float64
 	}
 )
 
 func Stat(cmd *cobra.Command, _ []string) error {
 	flags := cmd.Flags()
-	dir, err := flags.GetString("dir")
+	dir, filter, err := prepareScan(flags)
 	if err != nil {
 		return err
 	}
@@ -88,112 +125,127 @@ func Stat(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	dir, err = filepath.Abs(dir)
+	all, err := flags.GetBool("all")
 	if err != nil {
 		return err
 	}
 
-	types, err := flags.GetStringSlice("type")
+	err = handleColor(flags)
 	if err != nil {
 		return err
 	}
-	typeMap := make(map[string]struct{}, len(types))
-	for _, s := range types {
-		typeMap["."+s] = struct{}{}
-	}
 
-	filter, err := flags.GetString("filter")
+	limit, err := flags.GetInt64("limit")
 	if err != nil {
 		return err
 	}
 
-	regexpFilter, err := genRegexpFilter(filter)
+	recursion, err := flags.GetBool("recursion")
 	if err != nil {
 		return err
 	}
 
-	all, err := flags.GetBool("all")
+	directory, err := getDirectory(flags)
 	if err != nil {
 		return err
 	}
 
-	err = handleColor(flags)
+	interactive, err := flags.GetBool("interactive")
 	if err != nil {
 		return err
 	}
 
-	err = setWorker(flags)
+	format, err := getFormat(flags)
 	if err != nil {
 		return err
 	}
 
-	limit, err := flags.GetInt64("limit")
+	importPath, err := flags.GetString("import")
 	if err != nil {
 		return err
 	}
+	if importPath != "" && format == "ndjson" {
+		return errors.New("ndjson format can not be used with an imported file")
+	}
 
-	recursion, err := flags.GetBool("recursion")
+	err = setBreakdowns(flags)
 	if err != nil {
 		return err
 	}
+	if len(breakdowns) > 0 && (importPath != "" || format != "tree") {
+		return errors.New("by can only be used with the tree format on a scanned directory")
+	}
+	if interactive && (format != "tree" || len(breakdowns) > 0) {
+		return errors.New("interactive can only be used with the tree format")
+	}
 
-	directory, err := getDirectory(flags)
-	if err != nil {
-		return err
+	load := func() (*file, error) {
+		if importPath != "" {
+			return importNCDU(importPath)
+		}
+		return findRoot(dir, filter)
 	}
 
-	interactive, err := flags.GetBool("interactive")
-	if err != nil {
-		return err
+	cmd.SilenceUsage = true
+	if interactive {
+		var rescan func(dir string) (*file, error)
+		if importPath == "" {
+			rescan = func(dir string) (*file, error) { return findRoot(dir, filter) }
+		}
+		err = browse(dir, load, rescan, unit, all, directory)
+		if err != nil {
+			return err
+		}
+
+		printSkippedMounts()
+		return scanIncomplete()
 	}
 
 	go func() {
 		defer close(errChan)
 
-		files, err := find(dir, func(info fs.FileInfo) bool {
-			if info.IsDir() {
-				return true
-			}
-
-			name := info.Name()
-			ext := filepath.Ext(name)
-			_, ok := typeMap[ext]
-			typeB := ok || len(types) == 0
-
-			filterB := regexpFilter(name)
+		if format == "ndjson" {
+			startNDJSON()
+		}
 
-			return typeB && filterB
-		})
+		root, err := load()
 		if err != nil {
 			errChan <- err
 			return
 		}
-
-		totalSize := int64(0)
-		for _, f := range files {
-			totalSize += f.size
-		}
-
-		val, reduceUnit := getReduce(unit, totalSize)
-		header := fmt.Sprintf("Total: %0.3f%s\t%s", val, reduceUnit, color.HiGreenString(dir))
-		colorPrintln(header)
-		colorPrintln(strings.Repeat("─", len(header)+2))
-
-		l := list.NewWriter()
-		l.SetStyle(list.StyleConnectedLight)
+		dir, files, totalSize := root.name, root.sub, root.size
 
 		markPrint(files, limit, all, directory)
-		infoFiles := buildInfoFile(l, files, 0, depth, unit, totalSize, recursion)
-		maxLen := 0
-		for _, info := range infoFiles {
-			if maxLen < info.strLen {
-				maxLen = info.strLen
+		switch format {
+		case "ndjson":
+			err = stopNDJSON()
+		case "json":
+			err = printJSON(dir, files, depth, totalSize, recursion)
+		case "csv", "tsv":
+			err = printCSV(files, depth, unit, totalSize, recursion, format == "tsv")
+		case "html":
+			err = printHTML(dir, files, depth, unit, totalSize, recursion)
+		case "folded":
+			printFolded(root)
+		case "pprof":
+			err = printPprof(root)
+		case "ncdu":
+			err = exportNCDU(cmd, root)
+		default:
+			if len(breakdowns) > 0 {
+				printBreakdowns(dir, unit)
+				break
 			}
+			renderTree(dir, files, depth, unit, totalSize, recursion)
+		}
+		if err != nil {
+			errChan <- err
+			return
 		}
-		printTree(l.Render(), infoFiles, maxLen)
 
-		rendering(interactive, out.String())
-		errChan <- nil
+		fmt.Print(out.String())
+		printSkippedMounts()
+		errChan <- scanIncomplete()
 	}()
 
 	if err := <-errChan; err != nil {
@@ -203,6 +255,131 @@ func Stat(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
+// prepareScan applies the scan flags and returns the directory to scan along
+// with the filter deciding which files are counted.
+func prepareScan(flags *flag.FlagSet) (string, fileFilter, error) {
+	dir, err := getDir(flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	filter, err := getFilter(flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = setThresholds(flags, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = setWorker(flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = setHardLinks(flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = setOneFileSystem(flags, dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = setSkipFileSystems(flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = setSizeMode(flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = setDuCompat(flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = setExcludes(flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return dir, filter, nil
+}
+
+func getDir(flags *flag.FlagSet) (string, error) {
+	dir, err := flags.GetString("dir")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(dir)
+}
+
+// getFilter returns the filter deciding which files are counted by find.
+func getFilter(flags *flag.FlagSet) (fileFilter, error) {
+	types, err := flags.GetStringSlice("type")
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := flags.GetString("filter")
+	if err != nil {
+		return nil, err
+	}
+
+	regexpFilter, err := genRegexpFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	where, err := flags.GetString("where")
+	if err != nil {
+		return nil, err
+	}
+
+	wherePredicate := func(*whereFile) bool { return true }
+	if where != "" {
+		wherePredicate, err = parseWhere(where, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(path, rel string, info fs.FileInfo, disk int64) bool {
+		if info.IsDir() {
+			return true
+		}
+
+		name := info.Name()
+		typeB := len(types) == 0
+		for _, t := range types {
+			// multi-part types like tar.gz are matched as well.
+			if strings.HasSuffix(name, "."+t) {
+				typeB = true
+				break
+			}
+		}
+
+		filterB := regexpFilter(name)
+		if !typeB || !filterB {
+			return false
+		}
+
+		size := info.Size()
+		if !apparentSize {
+			size = disk
+		}
+
+		return withinThresholds(path, info, size) &&
+			wherePredicate(&whereFile{rel: rel, size: size, info: info})
+	}, nil
+}
+
 func setWorker(flags *flag.FlagSet) error {
 	workerNum, err := flags.GetInt("worker")
 	if err != nil {
@@ -240,13 +417,27 @@ func getUnit(flags *flag.FlagSet) (string, error) {
 	}
 
 	switch unit {
-	case "B", "K", "M", "G", "T":
+	case "B", "K", "M", "G", "T", "A":
 		return unit, nil
 	default:
 		return "", errors.New("invalid unit:" + unit)
 	}
 }
 
+func getFormat(flags *flag.FlagSet) (string, error) {
+	format, err := flags.GetString("format")
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "tree", "json", "ndjson", "csv", "tsv", "ncdu", "html", "folded", "pprof":
+		return format, nil
+	default:
+		return "", errors.New("invalid format:" + format)
+	}
+}
+
 func getDirectory(flags *flag.FlagSet) (bool, error) {
 	directory, err := flags.GetBool("directory")
 	if err != nil {
@@ -256,38 +447,99 @@ func getDirectory(flags *flag.FlagSet) (bool, error) {
 	return directory, nil
 }
 
-func find(dir string, filter func(info fs.FileInfo) bool) ([]*file, error) {
-	if !sysFilter(dir) {
-		return nil, nil
-	}
-
-	dirEntries, err := os.ReadDir(dir)
+// findRoot scans dir and returns it as the root of the tree.
+func findRoot(dir string, filter fileFilter) (*file, error) {
+	info, err := os.Stat(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, errors.New("no such directory")
 		}
 
-		return nil, nil
+		return nil, err
+	}
+
+	var files []*file
+	skipped := true
+	dev, _ := device(info)
+	if sysFilter(dir, dev) {
+		files, skipped, err = find(dir, "", 1, dev, excludeRules, filter)
+	}
+	root := &file{sub: files, name: dir, isDir: true, readError: err != nil, skipped: skipped}
+	root.sumSub()
+	root.addOwnSize(info, dir)
+	if visit != nil {
+		visit(dir, 0, root)
+	}
+
+	return root, nil
+}
+
+// find returns the files of dir, at rel from the scanned directory and on
+// device dev, that are neither ignored by rules nor rejected by filter, and
+// whether any entry of dir was left out. The errors met while reading dir are
+// recorded with addScanError, and a non-nil error is returned when dir could
+// not be read completely, along with the files that could.
+func find(dir, rel string, depth int, dev uint64, rules *ignoreRules, filter fileFilter) ([]*file, bool, error) {
+	progressDir(dir)
+	dirEntries, readErr := os.ReadDir(dir)
+	if readErr != nil {
+		addScanError(readErr)
 	}
+	rules = readIgnoreFiles(rules, dir, rel, dirEntries)
 
 	var wg = sync.WaitGroup{}
 	fileChan := make(chan *file, len(dirEntries))
+	skipped := false
 	for _, entry := range dirEntries {
 		entry := entry
+		entryRel := path.Join(rel, entry.Name())
+		if rules.ignored(entryRel, entry.IsDir()) {
+			skipped = true
+			continue
+		}
+
 		fileInfo, err := entry.Info()
 		if err != nil {
-			return nil, err
+			addScanError(err)
+			readErr = err
+			continue
 		}
 
-		if !filter(fileInfo) {
+		name := filepath.Join(dir, entry.Name())
+		var disk int64
+		if !entry.IsDir() {
+			disk = diskSize(fileInfo, name)
+		}
+		if !filter(name, entryRel, fileInfo, disk) {
+			skipped = true
 			continue
 		}
 
 		if !entry.IsDir() {
-			fileChan <- &file{
-				name: entry.Name(),
-				size: diskSize(fileInfo, filepath.Join(dir, entry.Name())),
+			f := &file{name: entry.Name(), mtime: fileInfo.ModTime()}
+			f.disk, f.apparent = disk, fileInfo.Size()
+			if f.link = newFileLink(fileInfo, f.disk, f.apparent); f.link != nil {
+				f.disk, f.apparent = f.link.counted()
+				f.links = 1
+			}
+			f.setSize()
+			account(name, fileInfo, f)
+			progressFile(f)
+			if visit != nil {
+				visit(name, depth, f)
 			}
+
+			fileChan <- f
+			continue
+		}
+
+		if otherFileSystem(fileInfo, name) {
+			skipped = true
+			continue
+		}
+		subDev, _ := device(fileInfo)
+		if subDev != dev && !sysFilter(name, subDev) {
+			skipped = true
 			continue
 		}
 
@@ -295,22 +547,24 @@ func find(dir string, filter func(info fs.FileInfo) bool) ([]*file, error) {
 		do := func() {
 			defer wg.Done()
 
-			subFiles, err := find(path.Join(dir, entry.Name()), filter)
-			if err != nil {
-				return
+			subFiles, subSkipped, err := find(name, entryRel, depth+1, subDev, rules, filter)
+			f := &file{
+				sub:       subFiles,
+				name:      entry.Name(),
+				isDir:     true,
+				readError: err != nil,
+				skipped:   subSkipped,
 			}
-
-			totalSize := int64(0)
-			for _, subFile := range subFiles {
-				totalSize += subFile.size
+			f.sumSub()
+			f.addOwnSize(fileInfo, name)
+			if visit != nil {
+				visit(name, depth, f)
 			}
-
-			fileChan <- &file{
-				sub:   subFiles,
-				name:  entry.Name(),
-				isDir: true,
-				size:  totalSize,
+			if stream {
+				f.sub = nil
 			}
+
+			fileChan <- f
 		}
 		w.Run(do)
 	}
@@ -324,10 +578,29 @@ func find(dir string, filter func(info fs.FileInfo) bool) ([]*file, error) {
 	}
 	sort.Slice(files, func(i, j int) bool { return files[i].size > files[j].size })
 
-	return files, nil
+	return files, skipped, readErr
 }
 
-func buildInfoFile(l list.Writer, files []*file, n, depth int64, unit string, totalSize int64, recursion bool) []fileInfo {
+func renderTree(dir string, files []*file, depth int64, unit string, totalSize int64, recursion bool) {
+	val, reduceUnit := getReduce(unit, totalSize)
+	header := fmt.Sprintf("Total: %0.3f%s\t%s", val, reduceUnit, color.HiGreenString(dir))
+	colorPrintln(header)
+	colorPrintln(strings.Repeat("─", len(header)+2))
+
+	l := list.NewWriter()
+	l.SetStyle(list.StyleConnectedLight)
+
+	infoFiles := buildInfoFile(l, files, "", 0, depth, unit, totalSize, recursion)
+	maxLen := 0
+	for _, info := range infoFiles {
+		if maxLen < info.strLen {
+			maxLen = info.strLen
+		}
+	}
+	printTree(l.Render(), infoFiles, maxLen)
+}
+
+func buildInfoFile(l list.Writer, files []*file, parent string, n, depth int64, unit string, totalSize int64, recursion bool) []fileInfo {
 	if n == depth && !recursion {
 		return nil
 	}
@@ -338,24 +611,37 @@ func buildInfoFile(l list.Writer, files []*file, n, depth int64, unit string, to
 			continue
 		}
 
+		filePath := path.Join(parent, f.name)
 		val, reduceUnit := getReduce(unit, f.size)
+		disk, diskUnit := getReduce(unit, f.disk)
+		apparent, apparentUnit := getReduce(unit, f.apparent)
 		infoFiles = append(infoFiles, fileInfo{
-			size:      val,
-			uint:      reduceUnit,
-			usageRate: float64(f.size) / float64(totalSize) * 100,
-			strLen:    len(fmt.Sprintf("%0.1f", val)),
-			isDir:     f.isDir,
+			path:         filePath,
+			bytes:        f.size,
+			size:         val,
+			uint:         reduceUnit,
+			usageRate:    usageRate(f.size, totalSize),
+			strLen:       len(fmt.Sprintf("%0.1f", val)),
+			isDir:        f.isDir,
+			disk:         disk,
+			diskUnit:     diskUnit,
+			apparent:     apparent,
+			apparentUnit: apparentUnit,
+			sparseRatio:  sparseRatio(f.disk, f.apparent),
 		})
 
 		name := f.name
 		if f.isDir {
 			name = color.HiGreenString(name)
 		}
+		if mark := f.errorMark(); mark != "" {
+			name = color.HiYellowString(mark) + " " + name
+		}
 		l.AppendItem(name)
 
 		if f.isDir {
 			l.Indent()
-			subUsageSizes := buildInfoFile(l, f.sub, n+1, depth, unit, totalSize, recursion)
+			subUsageSizes := buildInfoFile(l, f.sub, filePath, n+1, depth, unit, totalSize, recursion)
 			infoFiles = append(infoFiles, subUsageSizes...)
 			l.UnIndent()
 		}
@@ -378,7 +664,11 @@ func markPrint(files []*file, limit int64, all bool, directory bool) []fileInfo
 		cl.Remove(element)
 
 		f := element.Value.(*file)
-		if f.isDir && f.size == 0 && !all {
+		if f.isDir && f.fileCount() == 0 && !all && f.errorMark() == "" {
+			continue
+		}
+
+		if f.size < minSize {
 			continue
 		}
 
@@ -415,6 +705,13 @@ func getReduce(unit string, n int64) (float64, string) {
 		reduce = 3
 	case "T":
 		reduce = 4
+	case "A":
+		// auto picks the largest unit keeping a value of at least 1.
+		reduce = len(units) - 1
+		for reduce > 0 && n < units[reduce] {
+			reduce--
+		}
+		return float64(n) / float64(units[reduce]), unitStrings[reduce]
 	}
 
 	for {
@@ -439,6 +736,15 @@ func colorPrintln(a ...any) {
 func printTree(content string, infoFiles []fileInfo, maxLen int) {
 	size := len(infoFiles)
 	format := " %" + strconv.Itoa(maxLen) + ".1f%s %5.1f%%"
+
+	// with both sizes, the columns are the allocated size, the apparent size,
+	// the allocated size in percent of the apparent size and the usage rate.
+	diskLen, apparentLen := 0, 0
+	for _, info := range infoFiles {
+		diskLen = max(diskLen, len(fmt.Sprintf("%0.1f", info.disk)))
+		apparentLen = max(apparentLen, len(fmt.Sprintf("%0.1f", info.apparent)))
+	}
+	bothFormat := " %" + strconv.Itoa(diskLen) + ".1f%s %" + strconv.Itoa(apparentLen) + ".1f%s %6.1f%% %5.1f%%"
 	for i, line := range strings.Split(content, "\n") {
 		if i >= size {
 			continue
@@ -446,6 +752,10 @@ func printTree(content string, infoFiles []fileInfo, maxLen int) {
 
 		info := infoFiles[i]
 		str := fmt.Sprintf(format, info.size, info.uint, info.usageRate)
+		if bothSizes {
+			str = fmt.Sprintf(bothFormat, info.disk, info.diskUnit, info.apparent, info.apparentUnit,
+				info.sparseRatio, info.usageRate)
+		}
 		if info.isDir {
 			str = color.HiRedString(str)
 		}
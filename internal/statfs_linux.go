@@ -0,0 +1,128 @@
+//go:build linux
+
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const tmpfsMagic = 0x01021994
+
+// fileSystemMagics maps the f_type returned by statfs(2) to a file system
+// class, see linux/magic.h.
+var fileSystemMagics = map[uint32]string{
+	0x9fa0:     "proc",
+	0x62656572: "sysfs",
+	0x27e0eb:   "cgroup",
+	0x63677270: "cgroup", // cgroup2
+	0x64626720: "debugfs",
+	0x74726163: "tracefs",
+	0x6969:     "nfs",
+	0xff534d42: "cifs",
+	0xfe534d42: "cifs", // smb2
+	0x517b:     "cifs", // smb
+	0x65735546: "fuse",
+}
+
+var (
+	// fileSystemClasses caches the class of every device, so statfs is only
+	// called once per mounted file system.
+	fileSystemClasses   = map[uint64]string{}
+	fileSystemClassesMu sync.Mutex
+
+	devtmpfsDevices     map[uint64]bool
+	devtmpfsDevicesOnce sync.Once
+)
+
+// fileSystemClass returns the class of the file system on device dev holding
+// dir, or an empty class for a regular file system.
+func fileSystemClass(dir string, dev uint64) (string, bool) {
+	fileSystemClassesMu.Lock()
+	defer fileSystemClassesMu.Unlock()
+
+	if class, ok := fileSystemClasses[dev]; ok {
+		return class, true
+	}
+
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &statfs); err != nil {
+		return "", false
+	}
+
+	magic := uint32(statfs.Type)
+	class := fileSystemMagics[magic]
+	// devtmpfs reports the magic of tmpfs, so it is told apart by the
+	// file system type mounted on its device.
+	if magic == tmpfsMagic && isDevtmpfs(dev) {
+		class = "devtmpfs"
+	}
+	fileSystemClasses[dev] = class
+
+	return class, true
+}
+
+func isDevtmpfs(dev uint64) bool {
+	devtmpfsDevicesOnce.Do(func() {
+		devtmpfsDevices = map[uint64]bool{}
+
+		f, err := os.Open("/proc/self/mountinfo")
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		// 22 1 0:5 / /dev rw,nosuid shared:2 - devtmpfs devtmpfs rw
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			sep := -1
+			for i, field := range fields {
+				if field == "-" {
+					sep = i
+					break
+				}
+			}
+			if sep < 3 || sep+1 >= len(fields) || fields[sep+1] != "devtmpfs" {
+				continue
+			}
+
+			major, minor, ok := strings.Cut(fields[2], ":")
+			if !ok {
+				continue
+			}
+			ma, err1 := strconv.ParseUint(major, 10, 32)
+			mi, err2 := strconv.ParseUint(minor, 10, 32)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			devtmpfsDevices[mkdev(ma, mi)] = true
+		}
+	})
+
+	return devtmpfsDevices[dev]
+}
+
+// mkdev encodes a device number like makedev(3) of glibc.
+func mkdev(major, minor uint64) uint64 {
+	return (major&0xfffff000)<<32 | (major&0xfff)<<8 |
+		(minor&0xffffff00)<<12 | minor&0xff
+}
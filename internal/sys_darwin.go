@@ -19,9 +19,10 @@ package internal
 import (
 	"os"
 	"syscall"
+	"time"
 )
 
-func sysFilter(dir string) bool {
+func sysFilter(dir string, _ uint64) bool {
 	return "/dev" != dir
 }
 
@@ -34,3 +35,53 @@ func diskSize(info os.FileInfo, _ string) int64 {
 	}
 	return info.Size()
 }
+
+// inode returns the device and inode identifying the file, along with its
+// number of hard links.
+func inode(info os.FileInfo) (fileKey, uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, 0, false
+	}
+
+	return fileKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, uint64(stat.Nlink), true
+}
+
+// device returns the id of the device holding the file.
+func device(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return uint64(stat.Dev), true
+}
+
+// ownerIDs returns the user and group ids owning the file.
+func ownerIDs(info os.FileInfo) (uint32, uint32, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return stat.Uid, stat.Gid, true
+}
+
+// fileTime returns the access, status change or birth time of the file.
+func fileTime(info os.FileInfo, _, field string) (time.Time, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	switch field {
+	case "atime":
+		return time.Unix(stat.Atimespec.Unix()), true
+	case "ctime":
+		return time.Unix(stat.Ctimespec.Unix()), true
+	case "btime":
+		return time.Unix(stat.Birthtimespec.Unix()), true
+	}
+
+	return info.ModTime(), true
+}
@@ -19,10 +19,23 @@ package internal
 import (
 	"os"
 	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
-func sysFilter(dir string) bool {
-	return dir != "/proc"
+// sysFilter skips the directories on the file system classes selected by
+// --skip-fs, such as proc, sysfs or network mounts. It is only called for
+// the directories whose device dev differs from the one of their parent.
+func sysFilter(dir string, dev uint64) bool {
+	class, ok := fileSystemClass(dir, dev)
+	if !ok || !skipFileSystems[class] {
+		return true
+	}
+
+	skipMount(dir, class)
+
+	return false
 }
 
 // diskSize returns the actual number of bytes allocated on disk for the file,
@@ -34,3 +47,59 @@ func diskSize(info os.FileInfo, _ string) int64 {
 	}
 	return info.Size()
 }
+
+// inode returns the device and inode identifying the file, along with its
+// number of hard links.
+func inode(info os.FileInfo) (fileKey, uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, 0, false
+	}
+
+	return fileKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, uint64(stat.Nlink), true
+}
+
+// device returns the id of the device holding the file.
+func device(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return uint64(stat.Dev), true
+}
+
+// ownerIDs returns the user and group ids owning the file.
+func ownerIDs(info os.FileInfo) (uint32, uint32, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return stat.Uid, stat.Gid, true
+}
+
+// fileTime returns the access, status change or birth time of the file. The
+// birth time is read with statx, which not every file system supports.
+func fileTime(info os.FileInfo, name, field string) (time.Time, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	switch field {
+	case "atime":
+		return time.Unix(stat.Atim.Unix()), true
+	case "ctime":
+		return time.Unix(stat.Ctim.Unix()), true
+	case "btime":
+		var statx unix.Statx_t
+		err := unix.Statx(unix.AT_FDCWD, name, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &statx)
+		if err != nil || statx.Mask&unix.STATX_BTIME == 0 {
+			return time.Time{}, false
+		}
+		return time.Unix(statx.Btime.Sec, int64(statx.Btime.Nsec)), true
+	}
+
+	return info.ModTime(), true
+}
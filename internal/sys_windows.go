@@ -19,6 +19,7 @@ package internal
 import (
 	"os"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -27,7 +28,7 @@ var (
 	procGetCompressedFileSizeW = modKernel32.NewProc("GetCompressedFileSizeW")
 )
 
-func sysFilter(_ string) bool {
+func sysFilter(_ string, _ uint64) bool {
 	return true
 }
 
@@ -52,3 +53,39 @@ func diskSize(info os.FileInfo, name string) int64 {
 
 	return int64(uint64(high)<<32 | uint64(uint32(low)))
 }
+
+// inode is not supported on windows, so hard links are counted like
+// regular files.
+func inode(_ os.FileInfo) (fileKey, uint64, bool) {
+	return fileKey{}, 0, false
+}
+
+// device is not supported on windows, so the scan never stops at a mount point.
+func device(_ os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// ownerIDs is not supported on windows, where files have no uid and gid.
+func ownerIDs(_ os.FileInfo) (uint32, uint32, bool) {
+	return 0, 0, false
+}
+
+// fileTime returns the access or creation time of the file. Windows has no
+// status change time, so ctime is not supported.
+func fileTime(info os.FileInfo, _, field string) (time.Time, bool) {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	switch field {
+	case "atime":
+		return time.Unix(0, data.LastAccessTime.Nanoseconds()), true
+	case "ctime":
+		return time.Time{}, false
+	case "btime":
+		return time.Unix(0, data.CreationTime.Nanoseconds()), true
+	}
+
+	return info.ModTime(), true
+}
@@ -0,0 +1,127 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"io/fs"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	// minSize and maxSize bound the size of the counted files, a negative
+	// value meaning no bound. minSize also hides the smaller directories.
+	minSize, maxSize int64 = -1, -1
+	// newerThan and olderThan bound the time of the counted files, a zero
+	// value meaning no bound.
+	newerThan, olderThan time.Time
+	// timeField is the time compared by --newer-than and --older-than.
+	timeField = "mtime"
+)
+
+func setThresholds(flags *flag.FlagSet, now time.Time) error {
+	var err error
+	minSize, err = getSizeThreshold(flags, "min-size")
+	if err != nil {
+		return err
+	}
+
+	maxSize, err = getSizeThreshold(flags, "max-size")
+	if err != nil {
+		return err
+	}
+
+	newerThan, err = getTimeThreshold(flags, "newer-than", now)
+	if err != nil {
+		return err
+	}
+
+	olderThan, err = getTimeThreshold(flags, "older-than", now)
+	if err != nil {
+		return err
+	}
+
+	timeField, err = flags.GetString("time-field")
+	if err != nil {
+		return err
+	}
+
+	switch timeField {
+	case "mtime", "atime", "ctime", "btime":
+	default:
+		return errors.New("invalid time-field:" + timeField)
+	}
+
+	return nil
+}
+
+func getSizeThreshold(flags *flag.FlagSet, name string) (int64, error) {
+	v, err := flags.GetString(name)
+	if err != nil || v == "" {
+		return -1, err
+	}
+
+	size, err := parseSize(v)
+	if err != nil {
+		return -1, errors.New("invalid " + name + ":" + v)
+	}
+
+	return size, nil
+}
+
+// getTimeThreshold accepts an age like 90d, counted back from now, or a date
+// like 2006-01-02.
+func getTimeThreshold(flags *flag.FlagSet, name string, now time.Time) (time.Time, error) {
+	v, err := flags.GetString(name)
+	if err != nil || v == "" {
+		return time.Time{}, err
+	}
+
+	if age, err := parseDuration(v); err == nil {
+		return now.Add(-age), nil
+	}
+
+	if t, ok := parseDate(v); ok {
+		return t, nil
+	}
+
+	return time.Time{}, errors.New("invalid " + name + ":" + v)
+}
+
+// withinThresholds reports whether a file of the given size passes the size
+// and time thresholds. A file whose time is unknown, like the birth time on a
+// file system not recording it, never passes a time threshold.
+func withinThresholds(path string, info fs.FileInfo, size int64) bool {
+	if minSize >= 0 && size < minSize || maxSize >= 0 && size > maxSize {
+		return false
+	}
+
+	if newerThan.IsZero() && olderThan.IsZero() {
+		return true
+	}
+
+	t, ok := fileTime(info, path, timeField)
+	if !ok {
+		return false
+	}
+
+	if !newerThan.IsZero() && !t.After(newerThan) {
+		return false
+	}
+
+	return olderThan.IsZero() || t.Before(olderThan)
+}
@@ -0,0 +1,125 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type (
+	topFile struct {
+		path  string
+		size  int64
+		mtime time.Time
+	}
+
+	// topHeap is a min-heap of the largest files seen so far, the smallest
+	// of them on top.
+	topHeap []topFile
+)
+
+func (h topHeap) Len() int           { return len(h) }
+func (h topHeap) Less(i, j int) bool { return h[i].size < h[j].size }
+func (h topHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *topHeap) Push(x any)        { *h = append(*h, x.(topFile)) }
+func (h *topHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// Top prints the largest files of the whole tree, whatever their depth. Only
+// the n largest files are held while scanning.
+func Top(cmd *cobra.Command, _ []string) error {
+	flags := cmd.Flags()
+	dir, filter, err := prepareScan(flags)
+	if err != nil {
+		return err
+	}
+
+	n, err := flags.GetInt("number")
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return errors.New("invalid number:" + strconv.Itoa(n))
+	}
+
+	unit, err := getUnit(flags)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu    sync.Mutex
+		files = make(topHeap, 0, n)
+	)
+	stream = true
+	visit = func(path string, _ int, f *file) {
+		if f.isDir {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(files) < n {
+			heap.Push(&files, topFile{path: path, size: f.size, mtime: f.mtime})
+		} else if f.size > files[0].size {
+			files[0] = topFile{path: path, size: f.size, mtime: f.mtime}
+			heap.Fix(&files, 0)
+		}
+	}
+
+	cmd.SilenceUsage = true
+	_, err = findRoot(dir, filter)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].size != files[j].size {
+			return files[i].size > files[j].size
+		}
+		return files[i].path < files[j].path
+	})
+
+	sizes := make([]string, len(files))
+	sizeLen := 0
+	for i, f := range files {
+		sizes[i] = formatSize(unit, f.size)
+		sizeLen = max(sizeLen, len(sizes[i]))
+	}
+
+	format := "%" + strconv.Itoa(sizeLen) + "s  %s  %s"
+	for i, f := range files {
+		colorPrintln(fmt.Sprintf(format, sizes[i], f.mtime.Format(time.DateTime), f.path))
+	}
+	fmt.Print(out.String())
+
+	printSkippedMounts()
+
+	return scanIncomplete()
+}
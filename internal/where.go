@@ -0,0 +1,500 @@
+//   Copyright 2023 chenquan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// A where expression selects the files counted by find, for example
+//
+//	size > 100M && path =~ "^logs/" && mtime < 30d
+//
+// Comparisons are joined with &&, || and !, and grouped with parentheses.
+// The fields are:
+//   - path: the path relative to the scanned directory, using slashes;
+//   - name: the base name;
+//   - ext: the extension, including multi-part ones like .tar.gz. == and !=
+//     also match a trailing part, so ext == ".gz" holds for a.tar.gz;
+//   - size: the counted size, with the units B, K, M, G and T;
+//   - mtime: the modification time, compared with a date (2006-01-02 or
+//     2006-01-02T15:04:05) or a duration (s, m, h, d, w, y) standing for the
+//     time that long ago, so both mtime < 2006-01-02 and mtime < 30d select
+//     the files modified before;
+//   - owner and group: the name of the user and group, or their id;
+//   - mode: the mode as displayed by ls, like -rw-r--r--;
+//   - perm: the permission bits, written in octal like 0644;
+//   - type: file, dir, symlink, pipe, socket, device or other.
+//
+// Strings are quoted and support ==, !=, =~ and !~ (regular expressions),
+// the other fields ==, !=, <, <=, > and >=.
+
+type (
+	whereFile struct {
+		rel  string
+		size int64
+		info fs.FileInfo
+	}
+
+	wherePredicate func(f *whereFile) bool
+
+	whereToken struct {
+		kind  string // "ident", "op", "string", "literal", "(", ")", "eof"
+		value string
+		pos   int
+	}
+
+	whereParser struct {
+		tokens []whereToken
+		pos    int
+		now    time.Time
+	}
+)
+
+var (
+	whereStringFields = map[string]func(f *whereFile) string{
+		"path": func(f *whereFile) string { return f.rel },
+		"name": func(f *whereFile) string { return f.info.Name() },
+		"ext":  func(f *whereFile) string { return multiExt(f.info.Name()) },
+		"owner": func(f *whereFile) string {
+			uid, _, ok := ownerIDs(f.info)
+			if !ok {
+				return ""
+			}
+			return lookupUser(uid)
+		},
+		"group": func(f *whereFile) string {
+			_, gid, ok := ownerIDs(f.info)
+			if !ok {
+				return ""
+			}
+			return lookupGroup(gid)
+		},
+		"mode": func(f *whereFile) string { return f.info.Mode().String() },
+		"type": func(f *whereFile) string { return fileType(f.info.Mode()) },
+	}
+
+	sizeUnits = map[string]int64{
+		"": Bytes, "b": Bytes,
+		"k": KB, "kb": KB, "kib": KB,
+		"m": MB, "mb": MB, "mib": MB,
+		"g": GB, "gb": GB, "gib": GB,
+		"t": TB, "tb": TB, "tib": TB,
+	}
+
+	durationUnits = map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+		"y": 365 * 24 * time.Hour,
+	}
+
+	userNames  sync.Map
+	groupNames sync.Map
+)
+
+// parseWhere compiles a where expression into a predicate.
+func parseWhere(expr string, now time.Time) (wherePredicate, error) {
+	tokens, err := tokenizeWhere(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &whereParser{tokens: tokens, now: now}
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if token := p.peek(); token.kind != "eof" {
+		return nil, fmt.Errorf("where: unexpected %q at %d", token.value, token.pos)
+	}
+
+	return predicate, nil
+}
+
+func tokenizeWhere(expr string) ([]whereToken, error) {
+	var tokens []whereToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, whereToken{kind: string(c), value: string(c), pos: i})
+			i++
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			op := string(c)
+			for _, two := range []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||"} {
+				if strings.HasPrefix(expr[i:], two) {
+					op = two
+					break
+				}
+			}
+			if op == "=" || op == "&" || op == "|" {
+				return nil, fmt.Errorf("where: invalid operator %q at %d", op, i)
+			}
+			tokens = append(tokens, whereToken{kind: "op", value: op, pos: i})
+			i += len(op)
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(expr) && expr[j] != c; j++ {
+				if expr[j] == '\\' && j+1 < len(expr) {
+					j++
+				}
+				sb.WriteByte(expr[j])
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("where: unterminated string at %d", i)
+			}
+			tokens = append(tokens, whereToken{kind: "string", value: sb.String(), pos: i})
+			i = j + 1
+		case unicode.IsLetter(rune(c)) || c == '_':
+			j := i
+			for j < len(expr) && (unicode.IsLetter(rune(expr[j])) || expr[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, whereToken{kind: "ident", value: expr[i:j], pos: i})
+			i = j
+		case unicode.IsDigit(rune(c)) || c == '.':
+			j := i
+			for j < len(expr) && (unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j])) ||
+				strings.ContainsRune(".:-", rune(expr[j]))) {
+				j++
+			}
+			tokens = append(tokens, whereToken{kind: "literal", value: expr[i:j], pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("where: unexpected %q at %d", c, i)
+		}
+	}
+
+	return append(tokens, whereToken{kind: "eof", pos: len(expr)}), nil
+}
+
+func (p *whereParser) peek() whereToken {
+	return p.tokens[p.pos]
+}
+
+func (p *whereParser) next() whereToken {
+	token := p.tokens[p.pos]
+	if token.kind != "eof" {
+		p.pos++
+	}
+	return token
+}
+
+func (p *whereParser) parseOr() (wherePredicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().value == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *whereFile) bool { return l(f) || right(f) }
+	}
+
+	return left, nil
+}
+
+func (p *whereParser) parseAnd() (wherePredicate, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().value == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *whereFile) bool { return l(f) && right(f) }
+	}
+
+	return left, nil
+}
+
+func (p *whereParser) parseUnary() (wherePredicate, error) {
+	token := p.peek()
+	switch {
+	case token.kind == "op" && token.value == "!":
+		p.next()
+		predicate, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(f *whereFile) bool { return !predicate(f) }, nil
+	case token.kind == "(":
+		p.next()
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != ")" {
+			return nil, fmt.Errorf("where: missing ) at %d", closing.pos)
+		}
+		return predicate, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+func (p *whereParser) parseComparison() (wherePredicate, error) {
+	field := p.next()
+	if field.kind != "ident" {
+		return nil, fmt.Errorf("where: expected a field at %d", field.pos)
+	}
+
+	op := p.next()
+	if op.kind != "op" || op.value == "&&" || op.value == "||" || op.value == "!" {
+		return nil, fmt.Errorf("where: expected a comparison after %s at %d", field.value, op.pos)
+	}
+
+	value := p.next()
+	if value.kind != "string" && value.kind != "literal" && value.kind != "ident" {
+		return nil, fmt.Errorf("where: expected a value after %s %s at %d", field.value, op.value, value.pos)
+	}
+
+	switch field.value {
+	case "size":
+		size, err := parseSize(value.value)
+		if err != nil {
+			return nil, err
+		}
+		return compareInt(op, func(f *whereFile) int64 { return f.size }, size)
+	case "perm":
+		perm, err := strconv.ParseInt(value.value, 8, 64)
+		if err != nil {
+			return nil, fmt.Errorf("where: invalid permission %q", value.value)
+		}
+		return compareInt(op, func(f *whereFile) int64 { return int64(f.info.Mode().Perm()) }, perm)
+	case "mtime":
+		return p.parseTime(op, value)
+	}
+
+	get, ok := whereStringFields[field.value]
+	if !ok {
+		return nil, fmt.Errorf("where: unknown field %s at %d", field.value, field.pos)
+	}
+
+	return compareString(field.value, op, get, value.value)
+}
+
+func (p *whereParser) parseTime(op, value whereToken) (wherePredicate, error) {
+	t, ok := parseDate(value.value)
+	if age, err := parseDuration(value.value); err == nil {
+		t, ok = p.now.Add(-age), true
+	}
+	if ok {
+		return compareInt(op, func(f *whereFile) int64 { return f.info.ModTime().UnixNano() }, t.UnixNano())
+	}
+
+	return nil, fmt.Errorf("where: invalid time %q, expected a duration like 30d or a date like 2006-01-02", value.value)
+}
+
+func compareInt(op whereToken, get func(f *whereFile) int64, value int64) (wherePredicate, error) {
+	switch op.value {
+	case "==":
+		return func(f *whereFile) bool { return get(f) == value }, nil
+	case "!=":
+		return func(f *whereFile) bool { return get(f) != value }, nil
+	case "<":
+		return func(f *whereFile) bool { return get(f) < value }, nil
+	case "<=":
+		return func(f *whereFile) bool { return get(f) <= value }, nil
+	case ">":
+		return func(f *whereFile) bool { return get(f) > value }, nil
+	case ">=":
+		return func(f *whereFile) bool { return get(f) >= value }, nil
+	default:
+		return nil, fmt.Errorf("where: operator %s at %d can not compare numbers", op.value, op.pos)
+	}
+}
+
+func compareString(field string, op whereToken, get func(f *whereFile) string, value string) (wherePredicate, error) {
+	equal := func(f *whereFile) bool { return get(f) == value }
+	switch field {
+	case "ext":
+		equal = func(f *whereFile) bool {
+			ext := get(f)
+			return ext == value || strings.HasSuffix(ext, "."+strings.TrimPrefix(value, "."))
+		}
+	case "owner", "group":
+		if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+			equal = func(f *whereFile) bool {
+				uid, gid, ok := ownerIDs(f.info)
+				if !ok {
+					return false
+				}
+				if field == "owner" {
+					return uint64(uid) == id
+				}
+				return uint64(gid) == id
+			}
+		}
+	}
+
+	switch op.value {
+	case "==":
+		return equal, nil
+	case "!=":
+		return func(f *whereFile) bool { return !equal(f) }, nil
+	case "=~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("where: %w", err)
+		}
+		if op.value == "!~" {
+			return func(f *whereFile) bool { return !re.MatchString(get(f)) }, nil
+		}
+		return func(f *whereFile) bool { return re.MatchString(get(f)) }, nil
+	default:
+		return nil, fmt.Errorf("where: operator %s at %d can not compare %s", op.value, op.pos, field)
+	}
+}
+
+// parseSize parses a size like 100M, 1.5G or 4096.
+func parseSize(s string) (int64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) })
+	if i < 0 {
+		i = len(s)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return int64(n * float64(unit)), nil
+}
+
+// parseDuration parses a duration like 30d, 12h or 2w.
+func parseDuration(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) })
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	unit, ok := durationUnits[s[i:]]
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	return time.Duration(n * float64(unit)), nil
+}
+
+// parseDate parses a date, optionally with a time of day, in local time.
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04:05", time.RFC3339} {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// multiExt returns the extension of a file name including multi-part ones,
+// i.e. everything from the first dot that does not start the name.
+func multiExt(name string) string {
+	trimmed := strings.TrimLeft(name, ".")
+	i := strings.IndexByte(trimmed, '.')
+	if i < 0 {
+		return ""
+	}
+
+	return trimmed[i:]
+}
+
+func fileType(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "dir"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode&fs.ModeNamedPipe != 0:
+		return "pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeDevice != 0:
+		return "device"
+	default:
+		return "other"
+	}
+}
+
+// lookupUser returns the name of a user, or its id when it has none.
+func lookupUser(uid uint32) string {
+	if name, ok := userNames.Load(uid); ok {
+		return name.(string)
+	}
+
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+
+	return name
+}
+
+// lookupGroup returns the name of a group, or its id when it has none.
+func lookupGroup(gid uint32) string {
+	if name, ok := groupNames.Load(gid); ok {
+		return name.(string)
+	}
+
+	id := strconv.FormatUint(uint64(gid), 10)
+	name := id
+	if g, err := user.LookupGroupId(id); err == nil {
+		name = g.Name
+	}
+	groupNames.Store(gid, name)
+
+	return name
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+)
+
+type fakeInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (f fakeInfo) Name() string       { return f.name }
+func (f fakeInfo) Size() int64        { return f.size }
+func (f fakeInfo) Mode() fs.FileMode  { return f.mode }
+func (f fakeInfo) ModTime() time.Time { return f.modTime }
+func (f fakeInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f fakeInfo) Sys() any           { return nil }
+
+func TestParseWhere(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	f := &whereFile{
+		rel:  "logs/app/archive.tar.gz",
+		size: 200 * MB,
+		info: fakeInfo{
+			name:    "archive.tar.gz",
+			size:    200 * MB,
+			mode:    0o644,
+			modTime: now.Add(-45 * 24 * time.Hour),
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: `size > 100M && path =~ "^logs/" && mtime < 30d`, want: true},
+		{expr: `size > 1G`, want: false},
+		{expr: `mtime > 30d`, want: false},
+		{expr: `mtime < 2024-05-01`, want: true},
+		{expr: `ext == ".tar.gz" && ext == ".gz" && ext != ".zip"`, want: true},
+		{expr: `name =~ 'tar' && !(type == dir)`, want: true},
+		{expr: `perm == 0644 && mode == "-rw-r--r--"`, want: true},
+		{expr: `size <= 100M || path !~ "^logs/"`, want: false},
+		{expr: `type == file`, want: true},
+	}
+
+	for _, test := range tests {
+		predicate, err := parseWhere(test.expr, now)
+		if err != nil {
+			t.Errorf("parseWhere(%q): %v", test.expr, err)
+			continue
+		}
+		if got := predicate(f); got != test.want {
+			t.Errorf("parseWhere(%q) = %v, want %v", test.expr, got, test.want)
+		}
+	}
+
+	for _, expr := range []string{`size >`, `size = 1`, `foo == 1`, `size =~ "1"`, `(size > 1`, `mtime > yesterday`} {
+		if _, err := parseWhere(expr, now); err == nil {
+			t.Errorf("parseWhere(%q) should fail", expr)
+		}
+	}
+}